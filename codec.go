@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// codec reads and writes the files of winpos in one format.
+// The text formats hold the same fields as JSON: they are converted from
+// and to the JSON document of a value, so that the layout schema and
+// migrations apply whatever the format.
+type codec struct {
+	name      string
+	exts      []string // extensions of its files, the first one for the files written
+	marshal   func(v interface{}) ([]byte, error)
+	unmarshal func(b []byte, v interface{}) error
+	// decode decodes a document as generic JSON values, as decodeDocument;
+	// nil for the binary format, decoded straight into its type.
+	decode func(b []byte) (interface{}, error)
+}
+
+var (
+	jsonCodec = &codec{
+		name: "json",
+		exts: []string{".json"},
+		marshal: func(v interface{}) ([]byte, error) {
+			return json.MarshalIndent(v, "", "\t")
+		},
+		unmarshal: json.Unmarshal,
+		decode:    decodeDocument,
+	}
+	yamlCodec = &codec{
+		name:    "yaml",
+		exts:    []string{".yaml", ".yml"},
+		marshal: marshalYAML,
+		decode: func(b []byte) (interface{}, error) {
+			var doc interface{}
+			if err := yaml.Unmarshal(b, &doc); err != nil {
+				return nil, err
+			}
+			return jsonValues(doc)
+		},
+	}
+	tomlCodec = &codec{
+		name: "toml",
+		exts: []string{".toml"},
+		marshal: func(v interface{}) ([]byte, error) {
+			doc, err := jsonDocument(v)
+			if err != nil {
+				return nil, err
+			}
+			return toml.Marshal(tomlValues(doc))
+		},
+		decode: func(b []byte) (interface{}, error) {
+			var doc map[string]interface{}
+			if err := toml.Unmarshal(b, &doc); err != nil {
+				return nil, err
+			}
+			return jsonValues(doc)
+		},
+	}
+	// gobCodec is compact and fast, for the history snapshots nobody edits.
+	gobCodec = &codec{
+		name: "gob",
+		exts: []string{".gob"},
+		marshal: func(v interface{}) ([]byte, error) {
+			var buf bytes.Buffer
+			if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+				return nil, err
+			}
+			return buf.Bytes(), nil
+		},
+		unmarshal: func(b []byte, v interface{}) error {
+			return gob.NewDecoder(bytes.NewReader(b)).Decode(v)
+		},
+	}
+)
+
+// codecs are the formats of the files, JSON first.
+var codecs = []*codec{jsonCodec, yamlCodec, tomlCodec, gobCodec}
+
+func init() {
+	for _, c := range codecs {
+		if c.unmarshal == nil {
+			c.unmarshal = c.viaJSON
+		}
+	}
+}
+
+// codecByName returns the codec of a format name, nil if unknown.
+func codecByName(name string) *codec {
+	for _, c := range codecs {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// codecNames lists the formats, for the messages.
+func codecNames() string {
+	names := make([]string, len(codecs))
+	for i, c := range codecs {
+		names[i] = c.name
+	}
+	return strings.Join(names, ", ")
+}
+
+// codecOf returns the codec of a file, by its extension: JSON by default,
+// like for the file.tmp of the first winpos versions.
+func codecOf(path string) *codec {
+	if _, c := splitExt(filepath.Base(path)); c != nil {
+		return c
+	}
+	return jsonCodec
+}
+
+// splitExt splits a file name into its base and the codec of its extension,
+// nil if no codec reads it.
+func splitExt(name string) (string, *codec) {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, c := range codecs {
+		for _, e := range c.exts {
+			if ext == e {
+				return strings.TrimSuffix(name, filepath.Ext(name)), c
+			}
+		}
+	}
+	return name, nil
+}
+
+// ext is the extension of the files the codec writes.
+func (c *codec) ext() string {
+	return c.exts[0]
+}
+
+// viaJSON unmarshals a document of a text format into v,
+// through its JSON document.
+func (c *codec) viaJSON(b []byte, v interface{}) error {
+	doc, err := c.decode(b)
+	if err != nil {
+		return err
+	}
+	if b, err = json.Marshal(doc); err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// jsonDocument is the JSON document of v, as generic values.
+func jsonDocument(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return decodeDocument(b)
+}
+
+// jsonValues converts the generic values decoded from YAML or TOML
+// into the ones decoded from JSON by decodeDocument.
+func jsonValues(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case nil, bool, string:
+		return v, nil
+	case int:
+		return json.Number(strconv.Itoa(v)), nil
+	case int64:
+		return json.Number(strconv.FormatInt(v, 10)), nil
+	case uint64:
+		return json.Number(strconv.FormatUint(v, 10)), nil
+	case float64:
+		return json.Number(strconv.FormatFloat(v, 'g', -1, 64)), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			var err error
+			if m[k], err = jsonValues(e); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			var err error
+			if l[i], err = jsonValues(e); err != nil {
+				return nil, err
+			}
+		}
+		return l, nil
+	case []map[string]interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = e
+		}
+		return jsonValues(l)
+	case fmt.Stringer:
+		// TOML local dates and times
+		return v.String(), nil
+	}
+	return nil, fmt.Errorf("unexpected %T value %v", v, v)
+}
+
+// tomlValues converts a JSON document for TOML, which has neither null
+// nor arbitrary precision numbers.
+func tomlValues(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			if e != nil {
+				m[k] = tomlValues(e)
+			}
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = tomlValues(e)
+		}
+		return l
+	}
+	return v
+}
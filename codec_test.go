@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fullLayout is a layout with every field of its monitors and windows set,
+// and titles which other formats would take for numbers or booleans.
+func fullLayout() *layout {
+	monitors := testMonitors()
+	monitors[0].DeviceID = `\\?\DISPLAY#DEL40F3#5&1a2b3c&0&UID4352#{e6f07b5f-ee97-4a90-b076-33f57bf4eaa7}`
+	monitors[0].Serial = "ABC123"
+	snapped := &placement{State: stateNormal, Normal: rect{100, 100, 900, 700}, R: rect{-8, 0, 968, 1048},
+		MinPos: point{-1, -1}, MaxPos: point{-1, -1}}
+	minimized := &placement{State: stateMinimized, Normal: rect{2000, 50, 3000, 950}, R: rect{-32000, -32000, -31840, -31972},
+		MinPos: point{-32000, -32000}, MaxPos: point{1912, -8}, RestoreMaximized: true}
+	windows := []*window{
+		{Hwnd: 0x1234, Name: "Untitled - Notepad", Class: "Notepad", PID: 4242, Exe: `C:\Windows\System32\notepad.exe`,
+			Cmdline: `"C:\Windows\System32\notepad.exe" "C:\notes\a b.txt"`, Dir: `C:\notes`, TitleRegex: `^.* - Notepad$`,
+			R: snapped.R, DPI: 96, Placement: snapped, Z: 0, Foreground: true, Style: wsCaption, Caption: true},
+		{Hwnd: 0x5678, Name: "Café 日本語 ✓", Class: "ApplicationFrameWindow", PID: 17, AppID: "Microsoft.WindowsCalculator_8wekyb3d8bbwe!App",
+			R: minimized.R, DPI: 144, Placement: minimized, Z: 1, Maximize: true, Style: wsCaption | wsMaximize, Caption: true},
+		{Name: "123", Class: "yes", R: rect{0, 0, 10, 10}, Z: 2},
+		{Name: "yes", Class: "null", R: rect{-10, -10, 0, 0}, Z: 3},
+	}
+	return &layout{
+		Version:    layoutVersion,
+		Created:    time.Date(2024, 3, 4, 5, 6, 7, 890123456, time.FixedZone("CET", 3600)),
+		Host:       "HOST",
+		User:       `HOST\user`,
+		Monitors:   monitors,
+		Windows:    windows,
+		MonitorMap: map[string]string{`\\.\DISPLAY1`: `\\.\DISPLAY2`, "ABC123": "2"},
+	}
+}
+
+func TestCodecsRoundTrip(t *testing.T) {
+	want, err := json.Marshal(fullLayout())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range codecs {
+		t.Run(c.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "layout"+c.ext())
+			if err := Save(path, fullLayout()); err != nil {
+				t.Fatal(err)
+			}
+			var lay layout
+			if err := loadLayout(path, &lay); err != nil {
+				t.Fatal(err)
+			}
+			if !lay.Created.Equal(fullLayout().Created) {
+				t.Errorf("created %s, want %s", lay.Created, fullLayout().Created)
+			}
+			got, err := json.Marshal(&lay)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("read back as\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestCodecOf(t *testing.T) {
+	tests := []struct {
+		path string
+		want *codec
+	}{
+		{"work.json", jsonCodec},
+		{"work.YAML", yamlCodec},
+		{"work.yml", yamlCodec},
+		{"work.toml", tomlCodec},
+		{"work.gob", gobCodec},
+		{"file.tmp", jsonCodec},
+		{"work", jsonCodec},
+	}
+	for _, tt := range tests {
+		if got := codecOf(tt.path); got != tt.want {
+			t.Errorf("codecOf(%s) = %s, want %s", tt.path, got.name, tt.want.name)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	configVersion = 1
+	configFile    = "config.json"
+)
+
+// Restore strategies, for windows whose monitor changed since the record.
+const (
+	strategyScale = "scale" // translate and scale them into the current work area
+	strategyKeep  = "keep"  // keep their recorded rect, only clamped into the work area
+)
+
+// Collapse strategies, for the windows of recorded monitors now missing.
+const (
+	collapseNearest = "nearest" // onto the monitor closest to the missing one
+	collapsePrimary = "primary" // onto the primary monitor
+)
+
+// config is the winpos configuration file, in the per-user winpos directory.
+// Any setting it does not hold keeps its default value.
+type config struct {
+	Version       int
+	Store         string // layouts directory, <winpos directory>/layouts if empty
+	Format        string // format of the layouts written, see codecs
+	DefaultLayout string
+	Filters       struct {
+		// Style is the mask of the window styles a window must all have
+		// to be recorded, WS_VISIBLE | WS_CAPTION by default.
+		Style        uint32
+		RequireTitle bool   // ignore the windows without a title
+		TitleMax     int    // maximum title length read, in UTF-16 units
+		Rules        string // rules file, <winpos directory>/rules.json if empty
+	}
+	Restore struct {
+		Strategy      string   // strategyScale or strategyKeep
+		Collapse      string   // collapseNearest or collapsePrimary
+		Mapping       []string // monitor mapping strategies, applied in turn
+		Retries       int      // attempts after a failed placement
+		RetryDelay    duration
+		LaunchTimeout duration
+	}
+	History struct {
+		Keep   int // snapshots kept per layout
+		MaxAge duration
+		Format string // format of the snapshots written
+	}
+	Watch struct {
+		Interval duration
+		Debounce duration
+	}
+	Log struct {
+		File    string // log file, appended to, standard error if empty
+		Verbose bool
+	}
+}
+
+// conf is the configuration in effect.
+var conf = defaultConfig()
+
+func defaultConfig() *config {
+	c := &config{Version: configVersion, Format: jsonCodec.name, DefaultLayout: "default"}
+	c.Filters.Style = wsCaption
+	c.Filters.RequireTitle = true
+	c.Filters.TitleMax = 128
+	c.Restore.Collapse = collapseNearest
+	c.Restore.Strategy = strategyScale
+	c.Restore.Mapping = []string{"explicit", "device", "position"}
+	c.Restore.Retries = 2
+	c.Restore.RetryDelay = duration(200 * time.Millisecond)
+	c.Restore.LaunchTimeout = duration(30 * time.Second)
+	c.History.Keep = 20
+	c.History.MaxAge = duration(30 * 24 * time.Hour)
+	c.History.Format = gobCodec.name
+	c.Watch.Interval = duration(time.Minute)
+	c.Watch.Debounce = duration(5 * time.Second)
+	return c
+}
+
+// loadConfig reads the configuration file at path over the defaults.
+// A missing file gives the defaults.
+func loadConfig(path string) (*config, error) {
+	c := defaultConfig()
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return nil, fmt.Errorf("invalid configuration file '%s': %v", path, err)
+	}
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration file '%s': %v", path, err)
+	}
+	return c, nil
+}
+
+// validate reports all the invalid settings at once.
+func (c *config) validate() error {
+	var errs []string
+	check := func(ok bool, format string, a ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Sprintf(format, a...))
+		}
+	}
+	check(c.Version == configVersion, "Version %d is not supported, expected %d", c.Version, configVersion)
+	check(codecByName(c.Format) != nil, "Format '%s' is none of %s", c.Format, codecNames())
+	check(validName.MatchString(c.DefaultLayout), "DefaultLayout '%s' is not a valid layout name", c.DefaultLayout)
+	check(c.Filters.TitleMax >= 16 && c.Filters.TitleMax <= 4096, "Filters.TitleMax %d is not within [16, 4096]", c.Filters.TitleMax)
+	check(c.Restore.Strategy == strategyScale || c.Restore.Strategy == strategyKeep,
+		"Restore.Strategy '%s' is neither '%s' nor '%s'", c.Restore.Strategy, strategyScale, strategyKeep)
+	check(c.Restore.Collapse == collapseNearest || c.Restore.Collapse == collapsePrimary,
+		"Restore.Collapse '%s' is neither '%s' nor '%s'", c.Restore.Collapse, collapseNearest, collapsePrimary)
+	check(len(c.Restore.Mapping) > 0, "Restore.Mapping is empty")
+	for _, name := range c.Restore.Mapping {
+		check(mappingStrategies[name] != nil, "Restore.Mapping '%s' is none of %s", name, mappingNames())
+	}
+	check(c.Restore.Retries >= 0, "Restore.Retries %d is negative", c.Restore.Retries)
+	check(c.Restore.RetryDelay >= 0, "Restore.RetryDelay %s is negative", c.Restore.RetryDelay)
+	check(c.Restore.LaunchTimeout >= 0, "Restore.LaunchTimeout %s is negative", c.Restore.LaunchTimeout)
+	check(c.History.Keep >= 1, "History.Keep %d is less than 1", c.History.Keep)
+	check(c.History.MaxAge > 0, "History.MaxAge %s is not positive", c.History.MaxAge)
+	check(codecByName(c.History.Format) != nil, "History.Format '%s' is none of %s", c.History.Format, codecNames())
+	check(c.Watch.Interval > 0, "Watch.Interval %s is not positive", c.Watch.Interval)
+	check(c.Watch.Debounce >= 0, "Watch.Debounce %s is negative", c.Watch.Debounce)
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// duration is a time.Duration written as "30s" in the configuration file.
+type duration time.Duration
+
+func (d duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\"")
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+// setup applies the log settings.
+func (c *config) setup() error {
+	if c.Log.File == "" {
+		return nil
+	}
+	f, err := os.OpenFile(c.Log.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	log.SetOutput(io.MultiWriter(os.Stderr, f))
+	return nil
+}
+
+// debugf logs only with Log.Verbose.
+func debugf(format string, a ...interface{}) {
+	if conf.Log.Verbose {
+		log.Printf(format, a...)
+	}
+}
+
+// globalFlags are the flags before the command,
+// overriding the configuration file.
+type globalFlags struct {
+	config  string
+	store   string
+	logFile string
+	verbose bool
+	output  string // outputText, outputJSON or outputYAML
+	format  string // format of the layouts written
+
+	err error // invalid configuration file
+}
+
+func parseGlobalFlags(args []string) (*globalFlags, []string, error) {
+	g := &globalFlags{}
+	fs := flag.NewFlagSet("winpos", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&g.config, "config", "", "configuration file")
+	fs.StringVar(&g.store, "store", "", "layouts directory")
+	fs.StringVar(&g.logFile, "log", "", "log file")
+	fs.BoolVar(&g.verbose, "verbose", false, "verbose logs")
+	fs.StringVar(&g.output, "output", outputText, "output format: text, json or yaml")
+	fs.StringVar(&g.format, "format", "", "format of the layouts written: "+codecNames())
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, errUsage
+	}
+	switch g.output {
+	case outputText, outputJSON, outputYAML:
+	default:
+		return nil, nil, errUsage
+	}
+	if g.format != "" && codecByName(g.format) == nil {
+		return nil, nil, errUsage
+	}
+	return g, fs.Args(), nil
+}
+
+// configPath returns the configuration file: the --config one,
+// else the one in the per-user winpos directory.
+func (g *globalFlags) configPath() (string, error) {
+	if g.config != "" {
+		return g.config, nil
+	}
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, configFile), nil
+}
+
+// apply loads the configuration, and overrides it with the flags.
+func (g *globalFlags) apply() error {
+	path, err := g.configPath()
+	if err != nil {
+		return err
+	}
+	c, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+	if g.store != "" {
+		c.Store = g.store
+	}
+	if g.format != "" {
+		c.Format = g.format
+	}
+	if g.logFile != "" {
+		c.Log.File = g.logFile
+	}
+	if g.verbose {
+		c.Log.Verbose = true
+	}
+	conf = c
+	return conf.setup()
+}
+
+func cmdConfig(g *globalFlags, fs *flag.FlagSet, args []string) error {
+	force := fs.Bool("force", false, "overwrite an existing configuration file with init")
+	pos, err := parseArgs(fs, args)
+	if err != nil || len(pos) != 1 {
+		return errUsage
+	}
+	path, err := g.configPath()
+	if err != nil {
+		return err
+	}
+	switch pos[0] {
+	case "show":
+		if g.err != nil {
+			return g.err
+		}
+		b, err := jsonCodec.marshal(conf)
+		if err != nil {
+			return err
+		}
+		// The path goes to stderr, leaving valid JSON on stdout.
+		fmt.Fprintf(os.Stderr, "# %s\n", path)
+		fmt.Printf("%s\n", b)
+		return nil
+	case "validate":
+		if _, err := os.Stat(path); err != nil {
+			return err
+		}
+		// It was loaded and validated already, on start.
+		if g.err != nil {
+			return g.err
+		}
+		fmt.Printf("Winpos config: '%s' is valid\n", path)
+		return nil
+	case "init":
+		if _, err := os.Stat(path); err == nil && !*force {
+			return fmt.Errorf("configuration file '%s' already exists (use --force to overwrite it)", path)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return err
+		}
+		if err := Save(path, defaultConfig()); err != nil {
+			return err
+		}
+		fmt.Printf("Winpos config: '%s' created\n", path)
+		return nil
+	}
+	return errUsage
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig writes a configuration file, and returns its path.
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), configFile)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDefaultConfig(t *testing.T) {
+	if err := defaultConfig().validate(); err != nil {
+		t.Errorf("invalid default configuration: %v", err)
+	}
+	c, err := loadConfig(filepath.Join(t.TempDir(), configFile))
+	if err != nil || c.Restore.Retries != defaultConfig().Restore.Retries {
+		t.Errorf("missing configuration file: %+v, %v, want the defaults", c, err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	c, err := loadConfig(writeConfig(t, `{"Version": 1, "Format": "yaml", "Restore": {"Retries": 5, "RetryDelay": "1s"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Format != "yaml" || c.Restore.Retries != 5 || time.Duration(c.Restore.RetryDelay) != time.Second {
+		t.Errorf("settings not read: %+v", c)
+	}
+	if c.Restore.Strategy != strategyScale || c.History.Keep != 20 {
+		t.Errorf("settings not set lost their default: %+v", c)
+	}
+	tests := []struct {
+		name, content string
+		errs          []string
+	}{
+		{"unknown setting", `{"Version": 1, "Retries": 5}`, []string{`unknown field "Retries"`}},
+		{"number duration", `{"Version": 1, "Watch": {"Interval": 60}}`, []string{`duration must be a string like "30s"`}},
+		{"invalid duration", `{"Version": 1, "Watch": {"Interval": "1 minute"}}`, []string{`"1 minute"`}},
+		{"version", `{"Version": 2}`, []string{"Version 2 is not supported, expected 1"}},
+		{"invalid values", `{"Version": 1, "Format": "xml", "Restore": {"Mapping": ["rank", "edid"], "Retries": -1}, "History": {"Keep": 0}}`,
+			[]string{"Format 'xml' is none of json, yaml, toml, gob", "Restore.Mapping 'edid' is none of", "Restore.Retries -1 is negative", "History.Keep 0 is less than 1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, tt.content)
+			_, err := loadConfig(path)
+			if err == nil {
+				t.Fatal("invalid configuration loaded")
+			}
+			if !strings.Contains(err.Error(), path) {
+				t.Errorf("error %q without the file", err)
+			}
+			for _, want := range tt.errs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q without %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestGlobalFlags(t *testing.T) {
+	path := writeConfig(t, `{"Version": 1, "Store": "layouts", "Format": "yaml"}`)
+	g, args, err := parseGlobalFlags([]string{"--config", path, "--format", "toml", "--output", "json", "restore", "work"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(args, " ") != "restore work" || g.output != outputJSON {
+		t.Errorf("flags %+v, arguments %v", g, args)
+	}
+	setConfig(t, func(c *config) {})
+	if err := g.apply(); err != nil {
+		t.Fatal(err)
+	}
+	// flags over the file over the defaults
+	if conf.Format != "toml" || conf.Store != "layouts" || conf.Restore.Strategy != strategyScale {
+		t.Errorf("configuration %+v", conf)
+	}
+	for _, args := range [][]string{{"--output", "xml", "list"}, {"--format", "xml", "list"}, {"--unknown", "list"}} {
+		if _, _, err := parseGlobalFlags(args); err != errUsage {
+			t.Errorf("flags %v: error %v, want the usage", args, err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// csvColumns are the columns of the CSV files, in the order written.
+// The rect is the normal position of the window, in virtual screen
+// coordinates; the monitor is its rank, from 1, informative only.
+var csvColumns = []string{"title", "class", "exe", "left", "top", "right", "bottom", "state", "monitor"}
+
+var csvFormat = &exchangeFormat{name: "csv", ext: ".csv", read: readCSV, write: writeCSV}
+
+// readCSV reads one window per line, after a header naming the columns,
+// in any order. The exe, state and monitor columns are optional.
+func readCSV(b []byte, monitors []*monitor, opts *importOptions) ([]*window, error) {
+	records, err := csv.NewReader(bytes.NewReader(b)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no header line")
+	}
+	col := make(map[string]int)
+	for i, h := range records[0] {
+		col[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	for _, c := range []string{"title", "class", "left", "top", "right", "bottom"} {
+		if _, ok := col[c]; !ok {
+			return nil, fmt.Errorf("no '%s' column", c)
+		}
+	}
+	field := func(rec []string, c string) string {
+		if i, ok := col[c]; ok {
+			return strings.TrimSpace(rec[i])
+		}
+		return ""
+	}
+	l := make([]*window, 0, len(records)-1)
+	for n, rec := range records[1:] {
+		var v [4]int32
+		for i, c := range []string{"left", "top", "right", "bottom"} {
+			x, err := strconv.ParseInt(field(rec, c), 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid %s '%s'", n+2, c, field(rec, c))
+			}
+			v[i] = int32(x)
+		}
+		r := rect{Left: v[0], Top: v[1], Right: v[2], Bottom: v[3]}
+		if r.width() <= 0 || r.height() <= 0 {
+			return nil, fmt.Errorf("line %d: empty rect %s", n+2, r)
+		}
+		state := field(rec, "state")
+		switch state {
+		case "":
+			state = stateNormal
+		case stateNormal, stateMinimized, stateMaximized:
+		default:
+			return nil, fmt.Errorf("line %d: invalid state '%s': use %s, %s or %s", n+2, state, stateNormal, stateMinimized, stateMaximized)
+		}
+		l = append(l, importedWindow(field(rec, "title"), field(rec, "class"), field(rec, "exe"), r, state))
+	}
+	return l, nil
+}
+
+// writeCSV writes one window per line, after a header.
+func writeCSV(name string, lay *layout) ([]byte, error) {
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	cw.Write(csvColumns)
+	for _, w := range lay.Windows {
+		p := w.placement()
+		monitor := ""
+		if i := monitorIndex(p.Normal, lay.Monitors); i >= 0 {
+			monitor = strconv.Itoa(i + 1)
+		}
+		r := p.Normal
+		cw.Write([]string{w.Name, w.Class, w.Exe,
+			strconv.Itoa(int(r.Left)), strconv.Itoa(int(r.Top)), strconv.Itoa(int(r.Right)), strconv.Itoa(int(r.Bottom)),
+			p.State, monitor})
+	}
+	cw.Flush()
+	return buf.Bytes(), cw.Error()
+}
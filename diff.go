@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+)
+
+func cmdDiff(st *store, fs *flag.FlagSet, args []string) (result, error) {
+	at := fs.String("at", "", "compare a snapshot of the layout: its rank (0 for the latest) or a date and time")
+	selected := selectionFlags(fs)
+	name, err := layoutArg(fs, args, false)
+	if err != nil {
+		return nil, err
+	}
+	sel, err := selected()
+	if err != nil {
+		return nil, err
+	}
+	ws, err := newWindowSystem()
+	if err != nil {
+		return nil, err
+	}
+	return diff(ws, st, name, *at, sel)
+}
+
+// diffResult is what diff reports.
+type diffResult struct {
+	Layout       string
+	Topology     topology
+	TopologyDiff string       `json:",omitempty"`
+	Moved        []diffMove   // windows which restore would move
+	Missing      []windowInfo // recorded windows without a live counterpart
+	Unrecorded   []windowInfo // live windows not in the layout
+}
+
+type diffMove struct {
+	Name, Class string
+	From, To    placement
+}
+
+// printText prints the differences as:
+//
+//	~ windows which restore would move, from their current placement
+//	- recorded windows without a live counterpart
+//	+ live windows not in the layout
+func (r *diffResult) printText(w io.Writer) {
+	fmt.Fprintf(w, "Layout '%s': %s", r.Layout, r.Topology)
+	if r.TopologyDiff != "" {
+		fmt.Fprintf(w, ", %s", r.TopologyDiff)
+	}
+	fmt.Fprintln(w)
+	for _, mv := range r.Moved {
+		fmt.Fprintf(w, "~ %s\n    %s -> %s\n", mv.Name, mv.From, mv.To)
+	}
+	for _, wi := range r.Missing {
+		fmt.Fprintf(w, "- %s (%s) %s\n", wi.Name, wi.Class, wi.R)
+	}
+	for _, wi := range r.Unrecorded {
+		fmt.Fprintf(w, "+ %s (%s) %s\n", wi.Name, wi.Class, wi.R)
+	}
+}
+
+// diff compares the current desktop to a layout.
+func diff(ws WindowSystem, st *store, name, at string, sel *selection) (*diffResult, error) {
+	var lay layout
+	if err := st.loadAt(name, at, &lay); err != nil {
+		return nil, err
+	}
+	lay.Windows = sel.filter(lay.Windows, lay.Monitors)
+	p, err := planRestore(ws, &lay)
+	if err != nil {
+		return nil, err
+	}
+	r := &diffResult{
+		Layout:       name,
+		Topology:     p.Topology,
+		TopologyDiff: p.TopologyDiff,
+		Moved:        make([]diffMove, 0),
+		Missing:      windowInfos(p.Unmatched),
+		Unrecorded:   windowInfos(p.Unrecorded),
+	}
+	for _, mv := range p.Moves {
+		if !mv.From.same(mv.To) {
+			r.Moved = append(r.Moved, diffMove{Name: mv.Live.Name, Class: mv.Live.Class, From: mv.From, To: mv.To})
+		}
+	}
+	return r, nil
+}
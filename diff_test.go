@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	d, lay := restoreDesktop()
+	_, a, _ := d.find(1)
+	a.R = rect{0, 0, 800, 600} // already in place
+	d.Wins = append(d.Wins, &fakeWindow{Hwnd: 4, Name: "Calculator", Class: "Calc", Visible: true, Style: wsCaption, R: rect{10, 10, 300, 500}})
+	lay.Windows = append(lay.Windows, &window{Name: "Paint", Class: "MSPaintApp", R: rect{0, 0, 640, 480}})
+	st := &store{dir: t.TempDir()}
+	if err := st.save("work", lay); err != nil {
+		t.Fatal(err)
+	}
+	r, err := diff(d, st, "work", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Moved) != 2 || r.Moved[0].Name != "b - Term" || r.Moved[1].Name != "c - Term" {
+		t.Errorf("moved %+v, want b and c", r.Moved)
+	}
+	if len(r.Missing) != 1 || r.Missing[0].Name != "Paint" {
+		t.Errorf("missing %+v, want Paint", r.Missing)
+	}
+	if len(r.Unrecorded) != 1 || r.Unrecorded[0].Name != "Calculator" {
+		t.Errorf("unrecorded %+v, want Calculator", r.Unrecorded)
+	}
+	var out bytes.Buffer
+	r.printText(&out)
+	for _, want := range []string{"Layout 'work': same monitors\n", "~ b - Term\n", "- Paint (MSPaintApp)", "+ Calculator (Calc)"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("diff printed\n%s\nwithout %q", &out, want)
+		}
+	}
+	// diff moves nothing
+	if d.Wins[0].Hwnd != 3 || d.Active != 3 {
+		t.Errorf("diff restacked or activated windows")
+	}
+}
+
+func TestDiffSelection(t *testing.T) {
+	d, lay := restoreDesktop()
+	st := &store{dir: t.TempDir()}
+	if err := st.save("work", lay); err != nil {
+		t.Fatal(err)
+	}
+	res, err := diff(d, st, "work", "", &selection{include: []*rule{testRule(t, "include", "title:a*")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Moved) != 1 || res.Moved[0].Name != "a - Term" || len(res.Unrecorded) != 2 {
+		t.Errorf("moved %+v, %d unrecorded: want a moved only", res.Moved, len(res.Unrecorded))
+	}
+}
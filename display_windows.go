@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"github.com/lxn/win"
+)
+
+// dbtDevNodesChanged is the WM_DEVICECHANGE event of a device
+// added to or removed from the system.
+const dbtDevNodesChanged = 0x0007
+
+// DisplayChanges creates a hidden top-level window: unlike message-only
+// windows, it receives the WM_DISPLAYCHANGE and WM_DEVICECHANGE broadcasts.
+func (user32) DisplayChanges(stop <-chan struct{}) (<-chan struct{}, error) {
+	changes := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	}
+	created := make(chan error)
+	go func() {
+		// The window, and its messages, belong to the thread creating it.
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		wndProc := func(h win.HWND, msg uint32, wParam, lParam uintptr) uintptr {
+			switch {
+			case msg == win.WM_DISPLAYCHANGE,
+				msg == win.WM_DEVICECHANGE && wParam == dbtDevNodesChanged:
+				notify()
+			case msg == win.WM_DESTROY:
+				win.PostQuitMessage(0)
+			}
+			return win.DefWindowProc(h, msg, wParam, lParam)
+		}
+		className := syscall.StringToUTF16Ptr("winposWatch")
+		hInstance := win.GetModuleHandle(nil)
+		wc := win.WNDCLASSEX{
+			LpfnWndProc:   syscall.NewCallback(wndProc),
+			HInstance:     hInstance,
+			LpszClassName: className,
+		}
+		wc.CbSize = uint32(unsafe.Sizeof(wc))
+		if win.RegisterClassEx(&wc) == 0 {
+			created <- fmt.Errorf("RegisterClassEx failed")
+			return
+		}
+		h := win.CreateWindowEx(0, className, className, win.WS_OVERLAPPED,
+			0, 0, 0, 0, 0, 0, hInstance, nil)
+		if h == 0 {
+			created <- fmt.Errorf("CreateWindowEx failed")
+			return
+		}
+		created <- nil
+		go func() {
+			<-stop
+			win.PostMessage(h, win.WM_CLOSE, 0, 0)
+		}()
+		var msg win.MSG
+		for win.GetMessage(&msg, 0, 0, 0) > 0 {
+			win.TranslateMessage(&msg)
+			win.DispatchMessage(&msg)
+		}
+	}()
+	if err := <-created; err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
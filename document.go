@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// layoutVersion is the version of the layout format:
+//
+//	0  bare array of windows, the file.tmp of the first winpos versions
+//	1  object with the monitors and the windows, without version
+//	2  versioned document, with the time, host and user of the record
+const layoutVersion = 2
+
+// layoutSchemaJSON is the JSON Schema of the layout documents,
+// printed by 'winpos schema'.
+//
+//go:embed layout.schema.json
+var layoutSchemaJSON string
+
+var layoutSchema = compileSchema("winpos:///layout.schema.json", layoutSchemaJSON)
+
+func compileSchema(url, schema string) *jsonschema.Schema {
+	c := jsonschema.NewCompiler()
+	c.AssertFormat = true
+	if err := c.AddResource(url, strings.NewReader(schema)); err != nil {
+		panic(err)
+	}
+	return c.MustCompile(url)
+}
+
+// newLayout is a layout recorded now, by the current user.
+func newLayout(monitors []*monitor, windows []*window) *layout {
+	lay := &layout{Version: layoutVersion, Created: time.Now(), Monitors: monitors, Windows: windows}
+	lay.Host, _ = os.Hostname()
+	if u, err := user.Current(); err == nil {
+		lay.User = u.Username
+	}
+	return lay
+}
+
+// loadLayout loads the layout document at path, whatever its version
+// and its format (see codecOf).
+// Documents older than layoutVersion are migrated, with the time of the
+// file as time of the record.
+func loadLayout(path string, lay *layout) error {
+	lock.Lock()
+	b, err := os.ReadFile(path)
+	lock.Unlock()
+	if err != nil {
+		return err
+	}
+	var created time.Time
+	if fi, err := os.Stat(path); err == nil {
+		created = fi.ModTime()
+	}
+	if err := decodeLayout(codecOf(path), b, created, lay); err != nil {
+		return fmt.Errorf("invalid layout '%s': %v", path, err)
+	}
+	return nil
+}
+
+// decodeLayout migrates a layout document to layoutVersion,
+// validates it against the layout schema, and decodes it into lay.
+// Binary documents, written by this winpos version, are only validated.
+func decodeLayout(c *codec, b []byte, created time.Time, lay *layout) error {
+	if c.decode == nil {
+		*lay = layout{}
+		if err := c.unmarshal(b, lay); err != nil {
+			return err
+		}
+		if lay.Monitors == nil {
+			lay.Monitors = []*monitor{}
+		}
+		if lay.Windows == nil {
+			lay.Windows = []*window{}
+		}
+		return lay.validate()
+	}
+	doc, err := c.decode(b)
+	if err != nil {
+		return err
+	}
+	if doc, err = migrateLayout(doc, created); err != nil {
+		return err
+	}
+	if err := layoutSchema.Validate(doc); err != nil {
+		return err
+	}
+	if b, err = json.Marshal(doc); err != nil {
+		return err
+	}
+	*lay = layout{}
+	return json.Unmarshal(b, lay)
+}
+
+// decodeDocument decodes JSON as generic values, keeping the exact numbers.
+func decodeDocument(b []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// migrateLayout upgrades a layout document of an older version.
+func migrateLayout(doc interface{}, created time.Time) (interface{}, error) {
+	var m map[string]interface{}
+	switch d := doc.(type) {
+	case []interface{}:
+		m = map[string]interface{}{"Windows": d}
+	case map[string]interface{}:
+		m = d
+	default:
+		return nil, fmt.Errorf("not a layout document")
+	}
+	if v, ok := m["Version"].(json.Number); ok {
+		if n, err := v.Int64(); err == nil && n > layoutVersion {
+			return nil, fmt.Errorf("layout version %d is newer than the version %d this winpos reads", n, layoutVersion)
+		}
+		return m, nil
+	}
+	m["Version"] = json.Number(fmt.Sprint(layoutVersion))
+	m["Created"] = created.Format(time.RFC3339Nano)
+	if m["Monitors"] == nil {
+		m["Monitors"] = []interface{}{}
+	}
+	return m, nil
+}
+
+// validate checks lay against the layout schema.
+func (lay *layout) validate() error {
+	b, err := json.Marshal(lay)
+	if err != nil {
+		return err
+	}
+	doc, err := decodeDocument(b)
+	if err != nil {
+		return err
+	}
+	return layoutSchema.Validate(doc)
+}
+
+// sameAs tells whether two layouts record the same monitors and windows,
+// whenever they were recorded.
+func (lay *layout) sameAs(other *layout) bool {
+	a, b := *lay, *other
+	a.Created, b.Created = time.Time{}, time.Time{}
+	ja, err := json.Marshal(&a)
+	if err != nil {
+		return false
+	}
+	jb, err := json.Marshal(&b)
+	return err == nil && bytes.Equal(ja, jb)
+}
+
+func cmdSchema(fs *flag.FlagSet, args []string) error {
+	if pos, err := parseArgs(fs, args); err != nil || len(pos) != 0 {
+		return errUsage
+	}
+	fmt.Print(layoutSchemaJSON)
+	return nil
+}
+
+func cmdMigrate(st *store, fs *flag.FlagSet, args []string) (result, error) {
+	pos, err := parseArgs(fs, args)
+	if err != nil || len(pos) > 2 {
+		return nil, errUsage
+	}
+	file := "file.tmp"
+	if len(pos) > 0 {
+		file = pos[0]
+	}
+	name, err := layoutName(pos[min(len(pos), 1):], false)
+	if err != nil {
+		return nil, err
+	}
+	return migrate(st, file, name)
+}
+
+// migrateResult is what migrate reports.
+type migrateResult struct {
+	File    string
+	Layout  string
+	Windows int
+}
+
+func (r *migrateResult) printText(w io.Writer) {
+	fmt.Fprintf(w, "Winpos migrate: %d window(s) of '%s' saved in layout '%s'\n", r.Windows, r.File, r.Layout)
+}
+
+// migrate saves a layout file of any version, like the file.tmp
+// of the first winpos versions, as a stored layout.
+func migrate(st *store, file, name string) (*migrateResult, error) {
+	var lay layout
+	if err := loadLayout(file, &lay); err != nil {
+		return nil, err
+	}
+	if err := st.save(name, &lay); err != nil {
+		return nil, err
+	}
+	return &migrateResult{File: file, Layout: name, Windows: len(lay.Windows)}, nil
+}
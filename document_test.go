@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeLayoutVersions(t *testing.T) {
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	win := `{"Hwnd": 42, "Name": "a", "Class": "A", "R": {"Left": 0, "Top": 0, "Right": 800, "Bottom": 600}, "Z": 0, "Maximize": false, "Style": 0, "Caption": true}`
+	mon := `{"Device": "\\\\.\\DISPLAY1", "R": {"Left": 0, "Top": 0, "Right": 1920, "Bottom": 1080}, "Work": {"Left": 0, "Top": 0, "Right": 1920, "Bottom": 1040}, "DPI": 96, "Primary": true}`
+	tests := []struct {
+		name, doc string
+		created   time.Time
+		monitors  int
+	}{
+		{"file.tmp", "[" + win + "]", created, 0},
+		{"version 1", `{"Monitors": [` + mon + `], "Windows": [` + win + `]}`, created, 1},
+		{"version 2", `{"Version": 2, "Created": "2024-01-01T00:00:00Z", "Monitors": [` + mon + `], "Windows": [` + win + `]}`,
+			time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lay layout
+			if err := decodeLayout(jsonCodec, []byte(tt.doc), created, &lay); err != nil {
+				t.Fatal(err)
+			}
+			if lay.Version != layoutVersion || !lay.Created.Equal(tt.created) || len(lay.Monitors) != tt.monitors {
+				t.Errorf("version %d, created %s, %d monitor(s): want version %d, created %s, %d monitor(s)",
+					lay.Version, lay.Created, len(lay.Monitors), layoutVersion, tt.created, tt.monitors)
+			}
+			if len(lay.Windows) != 1 || lay.Windows[0].Name != "a" || lay.Windows[0].R != (rect{0, 0, 800, 600}) {
+				t.Errorf("windows %+v", lay.Windows)
+			}
+		})
+	}
+}
+
+func TestDecodeLayoutInvalid(t *testing.T) {
+	tests := []struct {
+		name, doc, err string
+	}{
+		{"newer", `{"Version": 3, "Windows": []}`, "layout version 3 is newer than the version 2 this winpos reads"},
+		{"not a layout", `"work"`, "not a layout document"},
+		{"not JSON", `{`, "unexpected EOF"},
+		{"missing windows", `{"Version": 2, "Created": "2024-01-01T00:00:00Z", "Monitors": []}`, "Windows"},
+		{"invalid state", `{"Version": 2, "Created": "2024-01-01T00:00:00Z", "Monitors": [], "Windows": [{"Hwnd": 1, "Name": "a", "Class": "A", "R": {"Left": 0, "Top": 0, "Right": 1, "Bottom": 1}, "Z": 0,
+			"Placement": {"State": "hidden", "Normal": {"Left": 0, "Top": 0, "Right": 1, "Bottom": 1}, "R": {"Left": 0, "Top": 0, "Right": 1, "Bottom": 1}, "MinPos": {"X": -1, "Y": -1}, "MaxPos": {"X": -1, "Y": -1}}}]}`, "/Windows/0/Placement/State"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lay layout
+			err := decodeLayout(jsonCodec, []byte(tt.doc), time.Now(), &lay)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestSameAs(t *testing.T) {
+	a := fullLayout()
+	b := fullLayout()
+	b.Created = b.Created.Add(time.Hour)
+	if !a.sameAs(b) {
+		t.Errorf("layouts recorded at different times told apart")
+	}
+	b.Windows[0].R.Left++
+	if a.sameAs(b) {
+		t.Errorf("layouts of different windows not told apart")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := fullLayout().validate(); err != nil {
+		t.Errorf("invalid full layout: %v", err)
+	}
+	lay := fullLayout()
+	lay.Windows[0].Placement.State = "hidden"
+	if err := lay.validate(); err == nil {
+		t.Errorf("layout of an invalid state validated")
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"github.com/lxn/win"
+)
+
+// setDPIAwareness declares the best DPI awareness available, as a manifest
+// would: per-monitor v2 (Windows 10 1703), per-monitor (Windows 8.1), else
+// system. Without it, Windows virtualizes the coordinates winpos gets and sets
+// for the windows on monitors not at 100% scaling, and they come back at the
+// wrong size on mixed-DPI setups.
+func setDPIAwareness() {
+	const (
+		dpiAwarenessContextPerMonitorAwareV2 = ^uintptr(3) // (DPI_AWARENESS_CONTEXT)-4
+		processPerMonitorDpiAware            = 2
+	)
+	if procSetProcessDpiAwarenessContext.Find() == nil {
+		if ret, _, _ := procSetProcessDpiAwarenessContext.Call(dpiAwarenessContextPerMonitorAwareV2); ret != 0 {
+			return
+		}
+	}
+	if procSetProcessDpiAwareness.Find() == nil {
+		if ret, _, _ := procSetProcessDpiAwareness.Call(processPerMonitorDpiAware); ret == 0 {
+			return
+		}
+	}
+	_, _, _ = procSetProcessDPIAware.Call()
+}
+
+// getWindowDPI returns the DPI of a window: the one of its monitor for
+// DPI-aware applications, 96 for the others, which Windows scales itself.
+// Before Windows 10 1607, it returns the DPI of its monitor.
+func getWindowDPI(h win.HWND) uint32 {
+	if procGetDpiForWindow.Find() == nil {
+		if ret, _, _ := procGetDpiForWindow.Call(uintptr(h)); ret != 0 {
+			return uint32(ret)
+		}
+	}
+	return getMonitorDPI(win.MonitorFromWindow(h, win.MONITOR_DEFAULTTONEAREST))
+}
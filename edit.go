@@ -0,0 +1,330 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Edit actions, of 'winpos edit <layout> <action>'.
+const (
+	editSet     = "set"
+	editMove    = "move"
+	editRemove  = "remove"
+	editMonitor = "monitor"
+	editSnap    = "snap"
+	editRename  = "rename"
+)
+
+// snapFractions are the parts of a monitor work area a window snaps to,
+// as left, top, right and bottom, in sixths.
+var snapFractions = map[string][4]int32{
+	"full":             {0, 0, 6, 6},
+	"left-half":        {0, 0, 3, 6},
+	"right-half":       {3, 0, 6, 6},
+	"top-half":         {0, 0, 6, 3},
+	"bottom-half":      {0, 3, 6, 6},
+	"top-left":         {0, 0, 3, 3},
+	"top-right":        {3, 0, 6, 3},
+	"bottom-left":      {0, 3, 3, 6},
+	"bottom-right":     {3, 3, 6, 6},
+	"left-third":       {0, 0, 2, 6},
+	"middle-third":     {2, 0, 4, 6},
+	"right-third":      {4, 0, 6, 6},
+	"left-two-thirds":  {0, 0, 4, 6},
+	"right-two-thirds": {2, 0, 6, 6},
+}
+
+func snapNames() string {
+	names := make([]string, 0, len(snapFractions))
+	for name := range snapFractions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// windowFields are the fields of 'edit set', by name.
+var windowFields = map[string]func(w *window, value string){
+	"title":   func(w *window, v string) { w.Name = v },
+	"class":   func(w *window, v string) { w.Class = v },
+	"exe":     func(w *window, v string) { w.Exe = v },
+	"cmdline": func(w *window, v string) { w.Cmdline = v },
+	"appid":   func(w *window, v string) { w.AppID = v },
+	"dir":     func(w *window, v string) { w.Dir = v },
+	"regex":   func(w *window, v string) { w.TitleRegex = v },
+	"state": func(w *window, v string) {
+		p := w.placement()
+		p.State = v
+		w.Placement = &p
+		w.Maximize = v == stateMaximized
+	},
+}
+
+// cmdEdit takes its arguments as they are, without flags,
+// for negative coordinates not to be taken for flags.
+func cmdEdit(st *store, fs *flag.FlagSet, args []string) (result, error) {
+	if len(args) < 2 {
+		return nil, errUsage
+	}
+	name, action, args := args[0], args[1], args[2:]
+	if action == editRename {
+		if len(args) != 1 {
+			return nil, errUsage
+		}
+		if err := st.rename(name, args[0]); err != nil {
+			return nil, err
+		}
+		return &editResult{Layout: args[0], Action: action, Renamed: name}, nil
+	}
+	if len(args) < 1 {
+		return nil, errUsage
+	}
+	spec := args[0]
+	var edit func(lay *layout, w *window) error
+	var err error
+	switch args = args[1:]; {
+	case action == editSet && len(args) > 0:
+		edit, err = setEdit(args)
+	case action == editMove && (len(args) == 1 || len(args) == 2):
+		edit, err = moveEdit(args)
+	case action == editRemove && len(args) == 0:
+	case action == editMonitor && len(args) == 1:
+		edit, err = monitorEdit(args[0])
+	case action == editSnap && (len(args) == 1 || len(args) == 2):
+		edit, err = snapEdit(args)
+	default:
+		return nil, errUsage
+	}
+	if err != nil {
+		return nil, err
+	}
+	return editLayout(st, name, action, spec, edit)
+}
+
+// editResult is what edit reports: the windows edited.
+type editResult struct {
+	Layout  string
+	Action  string
+	Renamed string       `json:",omitempty"` // previous name of the layout
+	Windows []windowInfo `json:",omitempty"`
+}
+
+func (r *editResult) printText(w io.Writer) {
+	if r.Action == editRename {
+		fmt.Fprintf(w, "Winpos edit: layout '%s' renamed '%s'\n", r.Renamed, r.Layout)
+		return
+	}
+	for _, wi := range r.Windows {
+		fmt.Fprintf(w, "  %-40s %-20s %s\n", wi.Name, wi.Class, wi.R)
+	}
+	fmt.Fprintf(w, "Winpos edit: %s %d window(s) of layout '%s'\n", r.Action, len(r.Windows), r.Layout)
+}
+
+// editLayout applies edit to the windows of the layout name meeting the
+// criteria of spec (see parseRule), or removes them for a nil edit,
+// then saves the layout in its format, checked against the layout schema.
+// The store stays locked from the load to the save, so that concurrent
+// edits all apply.
+func editLayout(st *store, name, action, spec string, edit func(lay *layout, w *window) error) (r *editResult, err error) {
+	match, err := parseRule("edit", spec)
+	if err != nil {
+		return nil, err
+	}
+	l, err := st.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if uerr := l.unlock(); err == nil {
+			err = uerr
+		}
+	}()
+	var lay layout
+	if err := st.load(name, &lay); err != nil {
+		return nil, err
+	}
+	file, err := st.file(name)
+	if err != nil {
+		return nil, err
+	}
+	r = &editResult{Layout: name, Action: action, Windows: make([]windowInfo, 0)}
+	kept := make([]*window, 0, len(lay.Windows))
+	for _, w := range lay.Windows {
+		if !match.matches(w, lay.Monitors) {
+			kept = append(kept, w)
+			continue
+		}
+		if edit != nil {
+			if err := edit(&lay, w); err != nil {
+				return nil, err
+			}
+			kept = append(kept, w)
+		}
+		r.Windows = append(r.Windows, newWindowInfo(w))
+	}
+	if len(r.Windows) == 0 {
+		return nil, fmt.Errorf("no window of layout '%s' matches '%s'", name, spec)
+	}
+	lay.Windows = kept
+	for i, w := range lay.Windows {
+		w.Z = i
+	}
+	if err := st.saveLocked(name, codecOf(file), &lay); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func setEdit(args []string) (func(lay *layout, w *window) error, error) {
+	sets := make([]func(w *window), 0, len(args))
+	for _, arg := range args {
+		i := strings.Index(arg, "=")
+		if i < 0 || windowFields[arg[:i]] == nil {
+			return nil, fmt.Errorf("'%s' is not <title|class|exe|cmdline|appid|dir|regex|state>=<value>", arg)
+		}
+		set, value := windowFields[arg[:i]], arg[i+1:]
+		sets = append(sets, func(w *window) { set(w, value) })
+	}
+	return func(lay *layout, w *window) error {
+		for _, set := range sets {
+			set(w)
+		}
+		return nil
+	}, nil
+}
+
+// moveEdit moves the windows to x,y, in virtual screen coordinates,
+// resized to width x height if given.
+func moveEdit(args []string) (func(lay *layout, w *window) error, error) {
+	x, y, err := parsePair(args[0], ",")
+	if err != nil {
+		return nil, fmt.Errorf("position '%s' is not <x>,<y>", args[0])
+	}
+	width, height := int32(-1), int32(-1)
+	if len(args) == 2 {
+		if width, height, err = parsePair(args[1], "x"); err != nil || width <= 0 || height <= 0 {
+			return nil, fmt.Errorf("size '%s' is not <width>x<height>", args[1])
+		}
+	}
+	return func(lay *layout, w *window) error {
+		r := w.placement().Normal
+		width, height := width, height
+		if width < 0 {
+			width, height = r.width(), r.height()
+		}
+		placeWindow(lay, w, rect{Left: x, Top: y, Right: x + width, Bottom: y + height}, true)
+		return nil
+	}, nil
+}
+
+// monitorEdit moves the windows to the monitor of rank arg, scaled from
+// the work area of their monitor to the one of the new monitor.
+func monitorEdit(arg string) (func(lay *layout, w *window) error, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("monitor '%s' is not a rank from 1", arg)
+	}
+	return func(lay *layout, w *window) error {
+		to, err := layoutMonitor(lay, n)
+		if err != nil {
+			return err
+		}
+		r := w.placement().Normal
+		from := lay.Monitors[monitorIndex(r, lay.Monitors)]
+		moveDPI(w, from, to)
+		placeWindow(lay, w, mapRect(r, workArea(from), workArea(to)), false)
+		return nil
+	}, nil
+}
+
+// snapEdit snaps the windows to a fraction of the work area of the monitor
+// of rank args[1], or of their own monitor.
+func snapEdit(args []string) (func(lay *layout, w *window) error, error) {
+	f, ok := snapFractions[args[0]]
+	if !ok {
+		return nil, fmt.Errorf("unknown fraction '%s': use %s", args[0], snapNames())
+	}
+	n := 0
+	if len(args) == 2 {
+		var err error
+		if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+			return nil, fmt.Errorf("monitor '%s' is not a rank from 1", args[1])
+		}
+	}
+	return func(lay *layout, w *window) error {
+		if len(lay.Monitors) == 0 {
+			return fmt.Errorf("layout records no monitor")
+		}
+		from := lay.Monitors[monitorIndex(w.placement().Normal, lay.Monitors)]
+		to := from
+		if n != 0 {
+			var err error
+			if to, err = layoutMonitor(lay, n); err != nil {
+				return err
+			}
+		}
+		moveDPI(w, from, to)
+		work := workArea(to)
+		x := func(sixths int32) int32 { return work.Left + int32(int64(work.width())*int64(sixths)/6) }
+		y := func(sixths int32) int32 { return work.Top + int32(int64(work.height())*int64(sixths)/6) }
+		placeWindow(lay, w, rect{Left: x(f[0]), Top: y(f[1]), Right: x(f[2]), Bottom: y(f[3])}, true)
+		return nil
+	}, nil
+}
+
+// layoutMonitor returns the monitor of rank n of a layout.
+func layoutMonitor(lay *layout, n int) (*monitor, error) {
+	if n > len(lay.Monitors) {
+		return nil, fmt.Errorf("no monitor %d, the layout records %d", n, len(lay.Monitors))
+	}
+	return lay.Monitors[n-1], nil
+}
+
+// moveDPI gives w the DPI of the monitor it moves to,
+// unless it is not DPI aware.
+func moveDPI(w *window, from, to *monitor) {
+	if w.DPI == from.DPI {
+		w.DPI = to.DPI
+	}
+}
+
+// placeWindow sets the normal position of w, unsnapped, and its state to
+// normal if asked. A maximized window fills the work area of the monitor
+// of its new normal position.
+func placeWindow(lay *layout, w *window, r rect, normal bool) {
+	p := w.placement()
+	p.Normal = r
+	if normal {
+		p.State = stateNormal
+		w.Maximize = false
+	}
+	switch {
+	case p.State == stateNormal:
+		p.R, w.R = r, r
+	case p.State == stateMaximized && len(lay.Monitors) > 0:
+		work := workArea(lay.Monitors[monitorIndex(r, lay.Monitors)])
+		p.R, w.R = work, work
+	}
+	w.Placement = &p
+}
+
+// parsePair parses two integers separated by sep, like "10,-20" or "800x600".
+func parsePair(s, sep string) (int32, int32, error) {
+	i := strings.Index(s, sep)
+	if i < 0 {
+		return 0, 0, fmt.Errorf("no '%s' in '%s'", sep, s)
+	}
+	a, err := strconv.ParseInt(s[:i], 10, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	b, err := strconv.ParseInt(s[i+len(sep):], 10, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	return int32(a), int32(b), nil
+}
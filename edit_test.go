@@ -0,0 +1,249 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// editStore is a store holding the layout "work", in YAML, of a notepad and
+// a maximized browser on the first monitor, and an editor on the second one.
+func editStore(t *testing.T) *store {
+	t.Helper()
+	browser := importedWindow("Inbox - Browser", "Chrome_WidgetWin_1", `C:\Apps\chrome.exe`, rect{200, 100, 1200, 800}, stateMaximized)
+	browser.R = rect{0, 0, 1920, 1040}
+	browser.Placement.R = browser.R
+	windows := []*window{
+		importedWindow("Untitled - Notepad", "Notepad", `C:\Windows\notepad.exe`, rect{100, 100, 900, 700}, stateNormal),
+		browser,
+		importedWindow("main.go - Code", "Chrome_WidgetWin_1", `C:\Apps\Code.exe`, rect{2000, 100, 2800, 700}, stateNormal),
+	}
+	for i, w := range windows {
+		w.Z = i
+		w.DPI = 96
+	}
+	windows[2].DPI = 144
+	st := &store{dir: t.TempDir()}
+	if err := st.saveIn("work", yamlCodec, newLayout(testMonitors(), windows)); err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+// edit runs 'winpos edit' with args, and returns the edited layout "work".
+func edit(t *testing.T, st *store, args ...string) *layout {
+	t.Helper()
+	if _, err := cmdEdit(st, flag.NewFlagSet("edit", flag.ContinueOnError), append([]string{"work"}, args...)); err != nil {
+		t.Fatal(err)
+	}
+	var lay layout
+	if err := st.load("work", &lay); err != nil {
+		t.Fatal(err)
+	}
+	return &lay
+}
+
+func TestEditMonitorMaximized(t *testing.T) {
+	lay := edit(t, editStore(t), "monitor", "exe:chrome.exe", "2")
+	w := lay.Windows[1]
+	p := w.placement()
+	if p.State != stateMaximized || !w.Maximize {
+		t.Errorf("state %s, maximize %t: want still maximized", p.State, w.Maximize)
+	}
+	if want := (rect{2120, 100, 3120, 800}); p.Normal != want {
+		t.Errorf("normal %s, want %s", p.Normal, want)
+	}
+	// maximized on the second monitor
+	if want := (rect{1920, 0, 3840, 1040}); w.R != want || p.R != want {
+		t.Errorf("rect %s, placement rect %s, want %s", w.R, p.R, want)
+	}
+	if w.DPI != 144 {
+		t.Errorf("DPI %d, want the one of the second monitor", w.DPI)
+	}
+}
+
+func TestEditMonitorNormal(t *testing.T) {
+	lay := edit(t, editStore(t), "monitor", "title:*Notepad", "2")
+	w := lay.Windows[0]
+	if want := (rect{2020, 100, 2820, 700}); w.R != want || w.placement().Normal != want {
+		t.Errorf("rect %s, normal %s, want %s", w.R, w.placement().Normal, want)
+	}
+}
+
+func TestEditMonitorEmpty(t *testing.T) {
+	// a hand-edited layout, of a first monitor without size
+	monitors := testMonitors()
+	monitors[0].R, monitors[0].Work = rect{}, rect{}
+	notepad := importedWindow("Untitled - Notepad", "Notepad", `C:\Windows\notepad.exe`, rect{100, 100, 900, 700}, stateNormal)
+	st := &store{dir: t.TempDir()}
+	if err := st.save("work", newLayout(monitors, []*window{notepad})); err != nil {
+		t.Fatal(err)
+	}
+	w := edit(t, st, "monitor", "title:*Notepad", "2").Windows[0]
+	if want := (rect{2020, 100, 2820, 700}); w.R != want {
+		t.Errorf("rect %s, want %s", w.R, want)
+	}
+}
+
+func TestEditConcurrent(t *testing.T) {
+	windows := make([]*window, 8)
+	for i := range windows {
+		windows[i] = importedWindow(fmt.Sprintf("w%d", i), "W", "", rect{0, 0, 800, 600}, stateNormal)
+	}
+	st := &store{dir: t.TempDir()}
+	if err := st.save("work", newLayout(testMonitors(), windows)); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, len(windows))
+	for i := range windows {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := editLayout(st, "work", editSet, fmt.Sprintf("title:w%d", i), func(lay *layout, w *window) error {
+				w.Class = "edited"
+				return nil
+			})
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	var lay layout
+	if err := st.load("work", &lay); err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range lay.Windows {
+		if w.Class != "edited" {
+			t.Errorf("edit of '%s' lost", w.Name)
+		}
+	}
+}
+
+func TestEditSnap(t *testing.T) {
+	tests := []struct {
+		args []string
+		i    int
+		want rect
+		dpi  uint32
+	}{
+		{[]string{"title:*Notepad", "left-half", "2"}, 0, rect{1920, 0, 2880, 1040}, 144},
+		{[]string{"exe:chrome.exe", "right-third"}, 1, rect{1280, 0, 1920, 1040}, 96},
+		{[]string{"exe:code.exe", "bottom-left", "1"}, 2, rect{0, 520, 960, 1040}, 96},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			lay := edit(t, editStore(t), append([]string{"snap"}, tt.args...)...)
+			w := lay.Windows[tt.i]
+			p := w.placement()
+			if p.State != stateNormal || w.Maximize {
+				t.Errorf("state %s, maximize %t: want normal", p.State, w.Maximize)
+			}
+			if w.R != tt.want || p.Normal != tt.want || p.R != tt.want {
+				t.Errorf("rect %s, normal %s, placement rect %s, want %s", w.R, p.Normal, p.R, tt.want)
+			}
+			if w.DPI != tt.dpi {
+				t.Errorf("DPI %d, want %d", w.DPI, tt.dpi)
+			}
+		})
+	}
+}
+
+func TestEditMove(t *testing.T) {
+	tests := []struct {
+		args []string
+		want rect
+	}{
+		{[]string{"-100,50"}, rect{-100, 50, 700, 650}},
+		{[]string{"10,20", "640x480"}, rect{10, 20, 650, 500}},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			lay := edit(t, editStore(t), append([]string{"move", "class:Notepad"}, tt.args...)...)
+			w := lay.Windows[0]
+			if w.R != tt.want || w.placement().Normal != tt.want {
+				t.Errorf("rect %s, normal %s, want %s", w.R, w.placement().Normal, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditMoveMaximized(t *testing.T) {
+	lay := edit(t, editStore(t), "move", "exe:chrome.exe", "0,0")
+	w := lay.Windows[1]
+	if want := (rect{0, 0, 1000, 700}); w.Maximize || w.placement().State != stateNormal || w.R != want {
+		t.Errorf("maximize %t, state %s, rect %s: want normal at %s", w.Maximize, w.placement().State, w.R, want)
+	}
+}
+
+func TestEditRemove(t *testing.T) {
+	lay := edit(t, editStore(t), "remove", "class:Chrome_*")
+	if len(lay.Windows) != 1 || lay.Windows[0].Class != "Notepad" || lay.Windows[0].Z != 0 {
+		t.Errorf("windows %v, want the notepad alone, at the top", lay.Windows)
+	}
+}
+
+func TestEditSet(t *testing.T) {
+	lay := edit(t, editStore(t), "set", "exe:notepad.exe", "regex=^.* - Notepad$", "dir=C:\\notes", "state=maximized")
+	w := lay.Windows[0]
+	if w.TitleRegex != "^.* - Notepad$" || w.Dir != `C:\notes` || !w.Maximize || w.placement().State != stateMaximized {
+		t.Errorf("window %+v %+v not set", w, w.Placement)
+	}
+}
+
+func TestEditKeepsFormat(t *testing.T) {
+	st := editStore(t)
+	edit(t, st, "remove", "exe:code.exe")
+	file, err := st.file("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Ext(file) != ".yaml" {
+		t.Errorf("layout edited into %s, want it kept in YAML", file)
+	}
+}
+
+func TestEditRename(t *testing.T) {
+	st := editStore(t)
+	if _, err := cmdEdit(st, flag.NewFlagSet("edit", flag.ContinueOnError), []string{"work", "rename", "home"}); err != nil {
+		t.Fatal(err)
+	}
+	if st.exists("work") || !st.exists("home") {
+		t.Errorf("layout not renamed")
+	}
+}
+
+func TestEditErrors(t *testing.T) {
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"work", "remove", "exe:vim.exe"}, "no window of layout 'work' matches 'exe:vim.exe'"},
+		{[]string{"work", "snap", "exe:*", "left-quarter"}, "unknown fraction 'left-quarter'"},
+		{[]string{"work", "snap", "exe:*", "full", "3"}, "no monitor 3, the layout records 2"},
+		{[]string{"work", "monitor", "exe:*", "0"}, "monitor '0' is not a rank from 1"},
+		{[]string{"work", "move", "exe:*", "10;20"}, "position '10;20' is not <x>,<y>"},
+		{[]string{"work", "move", "exe:*", "10,20", "0x20"}, "size '0x20' is not <width>x<height>"},
+		{[]string{"work", "set", "exe:*", "size=10"}, "'size=10' is not"},
+		{[]string{"work", "set", "pid:1", "title=a"}, "unknown criterion 'pid'"},
+		{[]string{"other", "remove", "exe:*"}, "no layout 'other'"},
+		{[]string{"work", "rename", "work"}, "layout 'work' already exists"},
+		{[]string{"work", "remove"}, errUsage.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			_, err := cmdEdit(editStore(t), flag.NewFlagSet("edit", flag.ContinueOnError), tt.args)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
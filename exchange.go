@@ -0,0 +1,170 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// exchangeFormat converts layouts from and to the files of another tool.
+type exchangeFormat struct {
+	name string
+	ext  string // extension of its files, picking the format by default
+	// read reads the windows of a file, onto the current monitors.
+	read func(b []byte, monitors []*monitor, opts *importOptions) ([]*window, error)
+	// write writes the windows of the layout name.
+	write func(name string, lay *layout) ([]byte, error)
+	// merge, when set, merges what write wrote into the existing file old,
+	// rather than overwriting it.
+	merge func(old, b []byte) ([]byte, error)
+}
+
+var exchangeFormats = []*exchangeFormat{csvFormat, fancyZonesFormat}
+
+// importOptions are the import flags.
+type importOptions struct {
+	zones   string // FancyZones layout to import, by name
+	monitor int    // rank of the monitor the zones go on, the primary one if 0
+}
+
+// exchangeFormatOf returns the format named as, or else the one of
+// the extension of path.
+func exchangeFormatOf(path, as string) (*exchangeFormat, error) {
+	names := make([]string, len(exchangeFormats))
+	for i, f := range exchangeFormats {
+		if as == f.name || as == "" && strings.EqualFold(filepath.Ext(path), f.ext) {
+			return f, nil
+		}
+		names[i] = f.name
+	}
+	if as != "" {
+		return nil, fmt.Errorf("unknown format '%s': use %s", as, strings.Join(names, " or "))
+	}
+	return nil, fmt.Errorf("cannot tell the format of '%s' from its extension: use --as %s", path, strings.Join(names, " or "))
+}
+
+func cmdImport(st *store, fs *flag.FlagSet, args []string) (result, error) {
+	as := fs.String("as", "", "format of the file: csv or fancyzones")
+	opts := &importOptions{}
+	fs.StringVar(&opts.zones, "zones", "", "FancyZones layout to import, by name")
+	fs.IntVar(&opts.monitor, "monitor", 0, "rank of the monitor the FancyZones zones go on")
+	pos, err := parseArgs(fs, args)
+	if err != nil || len(pos) < 1 || len(pos) > 2 {
+		return nil, errUsage
+	}
+	name, err := layoutName(pos[1:], false)
+	if err != nil {
+		return nil, err
+	}
+	f, err := exchangeFormatOf(pos[0], *as)
+	if err != nil {
+		return nil, err
+	}
+	ws, err := newWindowSystem()
+	if err != nil {
+		return nil, err
+	}
+	return importLayout(ws, st, f, pos[0], name, opts)
+}
+
+func cmdExport(st *store, fs *flag.FlagSet, args []string) (result, error) {
+	as := fs.String("as", "", "format of the file: csv or fancyzones")
+	pos, err := parseArgs(fs, args)
+	if err != nil || len(pos) < 1 || len(pos) > 2 {
+		return nil, errUsage
+	}
+	name, err := layoutName(pos[1:], false)
+	if err != nil {
+		return nil, err
+	}
+	f, err := exchangeFormatOf(pos[0], *as)
+	if err != nil {
+		return nil, err
+	}
+	return exportLayout(st, f, pos[0], name)
+}
+
+// importResult is what importLayout reports.
+type importResult struct {
+	File    string
+	Format  string
+	Layout  string
+	Windows int
+}
+
+func (r *importResult) printText(w io.Writer) {
+	fmt.Fprintf(w, "Winpos import: %d window(s) of '%s' (%s) saved in layout '%s'\n", r.Windows, r.File, r.Format, r.Layout)
+}
+
+// importLayout saves the windows of a file of another tool as the layout name,
+// with the current monitors.
+func importLayout(ws WindowSystem, st *store, f *exchangeFormat, file, name string, opts *importOptions) (*importResult, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	monitors, err := ws.Monitors()
+	if err != nil {
+		return nil, err
+	}
+	l, err := f.read(b, monitors, opts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s file '%s': %v", f.name, file, err)
+	}
+	for i, w := range l {
+		w.Z = i
+	}
+	if err := st.save(name, newLayout(monitors, l)); err != nil {
+		return nil, err
+	}
+	return &importResult{File: file, Format: f.name, Layout: name, Windows: len(l)}, nil
+}
+
+// exportResult is what exportLayout reports.
+type exportResult struct {
+	Layout  string
+	File    string
+	Format  string
+	Windows int
+}
+
+func (r *exportResult) printText(w io.Writer) {
+	fmt.Fprintf(w, "Winpos export: %d window(s) of layout '%s' written to '%s' (%s)\n", r.Windows, r.Layout, r.File, r.Format)
+}
+
+// exportLayout writes the windows of the layout name for another tool.
+func exportLayout(st *store, f *exchangeFormat, file, name string) (*exportResult, error) {
+	var lay layout
+	if err := st.load(name, &lay); err != nil {
+		return nil, err
+	}
+	b, err := f.write(name, &lay)
+	if err != nil {
+		return nil, err
+	}
+	if f.merge != nil {
+		old, err := os.ReadFile(file)
+		switch {
+		case err == nil:
+			if b, err = f.merge(old, b); err != nil {
+				return nil, fmt.Errorf("invalid %s file '%s', not overwritten: %v", f.name, file, err)
+			}
+		case !os.IsNotExist(err):
+			return nil, err
+		}
+	}
+	if err := writeFile(file, b); err != nil {
+		return nil, err
+	}
+	return &exportResult{Layout: name, File: file, Format: f.name, Windows: len(lay.Windows)}, nil
+}
+
+// importedWindow is a window read from the file of another tool,
+// placed in its normal state at r unless state says otherwise.
+func importedWindow(name, class, exe string, r rect, state string) *window {
+	p := &placement{State: state, Normal: r, R: r, MinPos: point{-1, -1}, MaxPos: point{-1, -1}}
+	return &window{Name: name, Class: class, Exe: exe, R: r, Placement: p, Maximize: state == stateMaximized}
+}
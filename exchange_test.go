@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCSVRoundTrip(t *testing.T) {
+	windows := []*window{
+		importedWindow("Untitled - Notepad", "Notepad", `C:\Windows\notepad.exe`, rect{10, 20, 810, 620}, stateNormal),
+		importedWindow(`Title, with "quotes"`, "Chrome_WidgetWin_1", "", rect{2000, 0, 3000, 900}, stateMaximized),
+	}
+	b, err := writeCSV("work", newLayout(testMonitors(), windows))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "title,class,exe,left,top,right,bottom,state,monitor\n"; !strings.HasPrefix(string(b), want) {
+		t.Errorf("header of %q, want %q", b, want)
+	}
+	if !strings.Contains(string(b), ",maximized,2\n") {
+		t.Errorf("no maximized window on monitor 2 in %q", b)
+	}
+	got, err := readCSV(b, testMonitors(), &importOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(windows) {
+		t.Fatalf("read %d windows, want %d", len(got), len(windows))
+	}
+	for i, w := range windows {
+		g := got[i]
+		if g.Name != w.Name || g.Class != w.Class || g.Exe != w.Exe || g.Maximize != w.Maximize || *g.Placement != *w.Placement {
+			t.Errorf("window %d read as %+v %+v, want %+v %+v", i, g, g.Placement, w, w.Placement)
+		}
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	tests := []struct {
+		name, csv, err string
+	}{
+		{"columns in any order", "Bottom,Right,Top,Left,Class,Title\n600,800,0,0,Notepad,a\n", ""},
+		{"empty", "", "no header line"},
+		{"missing column", "title,class,left,top,right\n", "no 'bottom' column"},
+		{"invalid number", "title,class,left,top,right,bottom\na,b,x,0,10,10\n", "line 2: invalid left 'x'"},
+		{"empty rect", "title,class,left,top,right,bottom\na,b,10,0,10,10\n", "line 2: empty rect"},
+		{"invalid state", "title,class,left,top,right,bottom,state\na,b,0,0,10,10,hidden\n", "line 2: invalid state 'hidden'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readCSV([]byte(tt.csv), testMonitors(), &importOptions{})
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+// fancyZonesFile is a custom-layouts.json file holding one layout.
+func fancyZonesFile(t *testing.T, name, typ string, info interface{}) []byte {
+	t.Helper()
+	b, err := json.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err = json.Marshal(fancyZones{CustomLayouts: []fancyZonesLayout{{UUID: "{0}", Name: name, Type: typ, Info: b}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestReadFancyZonesGrid(t *testing.T) {
+	// a left column, and two zones on the right
+	grid := fancyZonesGrid{Rows: 2, Columns: 2, RowsPercentage: []int32{5000, 5000}, ColumnsPercentage: []int32{5000, 5000},
+		CellChildMap: [][]int{{0, 1}, {0, 2}}}
+	l, err := readFancyZones(fancyZonesFile(t, "grid", "grid", grid), testMonitors(), &importOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// onto the primary monitor
+	want := []rect{{1920, 0, 2880, 1040}, {2880, 0, 3840, 520}, {2880, 520, 3840, 1040}}
+	if len(l) != len(want) {
+		t.Fatalf("%d zones, want %d", len(l), len(want))
+	}
+	for i, w := range l {
+		if w.R != want[i] || w.Name != "grid zone "+string(rune('1'+i)) {
+			t.Errorf("zone %d: '%s' %s, want %s", i+1, w.Name, w.R, want[i])
+		}
+	}
+}
+
+func TestReadFancyZonesCanvas(t *testing.T) {
+	canvas := fancyZonesCanvas{RefWidth: 1000, RefHeight: 500, Zones: []fancyZone{{X: 0, Y: 0, Width: 500, Height: 250}}}
+	l, err := readFancyZones(fancyZonesFile(t, "canvas", "canvas", canvas), testMonitors(), &importOptions{monitor: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (rect{0, 0, 960, 520}); len(l) != 1 || l[0].R != want {
+		t.Errorf("zones %v, want one at %s", l, want)
+	}
+}
+
+func TestReadFancyZonesErrors(t *testing.T) {
+	grid := func(rows, cols []int32) fancyZonesGrid {
+		cells := make([][]int, len(rows))
+		for r := range cells {
+			cells[r] = make([]int, len(cols))
+		}
+		return fancyZonesGrid{Rows: len(rows), Columns: len(cols), RowsPercentage: rows, ColumnsPercentage: cols, CellChildMap: cells}
+	}
+	tests := []struct {
+		name string
+		typ  string
+		info interface{}
+		opts importOptions
+		err  string
+	}{
+		{"zero width grid", "grid", grid([]int32{10000}, []int32{0}), importOptions{}, "invalid grid size 0x10000"},
+		{"zero height grid", "grid", grid([]int32{0, 0}, []int32{10000}), importOptions{}, "invalid grid size 10000x0"},
+		{"empty grid", "grid", grid(nil, nil), importOptions{}, "invalid grid size 0x0"},
+		{"negative percentage", "grid", grid([]int32{12000, -2000}, []int32{10000}), importOptions{}, "negative percentage -2000"},
+		{"inconsistent grid", "grid", fancyZonesGrid{Rows: 2, Columns: 1, RowsPercentage: []int32{10000}, ColumnsPercentage: []int32{10000}}, importOptions{}, "inconsistent sizes"},
+		{"zero canvas", "canvas", fancyZonesCanvas{}, importOptions{}, "invalid reference size 0x0"},
+		{"unknown type", "priority-grid", struct{}{}, importOptions{}, "unknown type 'priority-grid'"},
+		{"unknown layout", "grid", grid([]int32{10000}, []int32{10000}), importOptions{zones: "other"}, "no custom layout 'other'"},
+		{"unknown monitor", "grid", grid([]int32{10000}, []int32{10000}), importOptions{monitor: 3}, "no monitor 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readFancyZones(fancyZonesFile(t, "l", tt.typ, tt.info), testMonitors(), &tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestFancyZonesRoundTrip(t *testing.T) {
+	windows := []*window{
+		importedWindow("a", "A", "", rect{100, 50, 900, 650}, stateNormal),
+		importedWindow("b", "B", "", rect{2000, 100, 2500, 600}, stateMaximized),
+	}
+	b, err := writeFancyZones("work", newLayout(testMonitors(), windows))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []rect{{100, 50, 900, 650}, {1920, 0, 3840, 1040}} {
+		opts := &importOptions{zones: "work " + string(rune('1'+i)), monitor: i + 1}
+		l, err := readFancyZones(b, testMonitors(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(l) != 1 || l[0].R != want {
+			t.Errorf("zones of '%s': %v, want one at %s", opts.zones, l, want)
+		}
+	}
+}
+
+func TestExportFancyZonesMerge(t *testing.T) {
+	st := &store{dir: t.TempDir()}
+	windows := []*window{importedWindow("a", "A", "", rect{100, 50, 900, 650}, stateNormal)}
+	if err := st.save("work", newLayout(testMonitors(), windows)); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "custom-layouts.json")
+	old := fancyZones{CustomLayouts: []fancyZonesLayout{
+		{UUID: "{1}", Name: "mine", Type: "grid", Info: json.RawMessage(`{"rows": 1}`)},
+		{UUID: "{2}", Name: "work 1", Type: "grid", Info: json.RawMessage(`{"rows": 2}`)},
+	}}
+	b, err := json.Marshal(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := exportLayout(st, fancyZonesFormat, file, "work"); err != nil {
+		t.Fatal(err)
+	}
+	var fz fancyZones
+	if b, err = os.ReadFile(file); err == nil {
+		err = json.Unmarshal(b, &fz)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := fz.CustomLayouts; len(l) != 2 || l[0].Name != "mine" || l[1].Name != "work 1" || l[1].UUID != "{2}" || l[1].Type != "canvas" {
+		t.Errorf("custom layouts %+v, want mine kept and work 1 replaced", l)
+	}
+	// a file which is not a FancyZones one is left as it was
+	if err := os.WriteFile(file, []byte("notes"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := exportLayout(st, fancyZonesFormat, file, "work"); err == nil || !strings.Contains(err.Error(), "not overwritten") {
+		t.Errorf("error %v exporting over another file", err)
+	}
+	if b, _ := os.ReadFile(file); string(b) != "notes" {
+		t.Errorf("file overwritten with %s", b)
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// fancyZones is the custom-layouts.json file of PowerToys FancyZones,
+// in %LOCALAPPDATA%\Microsoft\PowerToys\FancyZones.
+type fancyZones struct {
+	CustomLayouts []fancyZonesLayout `json:"custom-layouts"`
+}
+
+type fancyZonesLayout struct {
+	UUID string          `json:"uuid"`
+	Name string          `json:"name"`
+	Type string          `json:"type"` // canvas or grid
+	Info json.RawMessage `json:"info"`
+}
+
+// fancyZonesCanvas holds free zones, in pixels of a reference screen.
+type fancyZonesCanvas struct {
+	RefWidth          int32       `json:"ref-width"`
+	RefHeight         int32       `json:"ref-height"`
+	Zones             []fancyZone `json:"zones"`
+	SensitivityRadius int         `json:"sensitivity-radius"`
+}
+
+type fancyZone struct {
+	X      int32 `json:"X"`
+	Y      int32 `json:"Y"`
+	Width  int32 `json:"width"`
+	Height int32 `json:"height"`
+}
+
+// fancyZonesGrid holds zones of grid cells, sized in 1/10000th of the screen:
+// CellChildMap gives the zone of each cell.
+type fancyZonesGrid struct {
+	Rows              int     `json:"rows"`
+	Columns           int     `json:"columns"`
+	RowsPercentage    []int32 `json:"rows-percentage"`
+	ColumnsPercentage []int32 `json:"columns-percentage"`
+	CellChildMap      [][]int `json:"cell-child-map"`
+}
+
+// fancyZonesSensitivity is the default sensitivity radius of FancyZones.
+const fancyZonesSensitivity = 20
+
+var fancyZonesFormat = &exchangeFormat{name: "fancyzones", ext: ".json", read: readFancyZones, write: writeFancyZones, merge: mergeFancyZones}
+
+// readFancyZones reads the zones of a FancyZones custom layout, the only one
+// of the file or the one of opts.zones, as windows named after them,
+// onto the work area of the monitor of opts.monitor.
+func readFancyZones(b []byte, monitors []*monitor, opts *importOptions) ([]*window, error) {
+	var fz fancyZones
+	if err := json.Unmarshal(b, &fz); err != nil {
+		return nil, err
+	}
+	var fl *fancyZonesLayout
+	names := make([]string, 0, len(fz.CustomLayouts))
+	for i := range fz.CustomLayouts {
+		names = append(names, "'"+fz.CustomLayouts[i].Name+"'")
+		if fz.CustomLayouts[i].Name == opts.zones || opts.zones == "" && len(fz.CustomLayouts) == 1 {
+			fl = &fz.CustomLayouts[i]
+		}
+	}
+	switch {
+	case len(names) == 0:
+		return nil, fmt.Errorf("no custom layout")
+	case fl == nil && opts.zones == "":
+		return nil, fmt.Errorf("%d custom layouts, pick one with --zones: %s", len(names), strings.Join(names, ", "))
+	case fl == nil:
+		return nil, fmt.Errorf("no custom layout '%s', only %s", opts.zones, strings.Join(names, ", "))
+	}
+	zones, ref, err := fl.zones()
+	if err != nil {
+		return nil, fmt.Errorf("custom layout '%s': %v", fl.Name, err)
+	}
+	if len(monitors) == 0 {
+		return nil, fmt.Errorf("no monitor to place the zones on")
+	}
+	i := primaryMonitor(monitors)
+	if opts.monitor != 0 {
+		if opts.monitor < 1 || opts.monitor > len(monitors) {
+			return nil, fmt.Errorf("no monitor %d, only %d", opts.monitor, len(monitors))
+		}
+		i = opts.monitor - 1
+	}
+	work := workArea(monitors[i])
+	l := make([]*window, 0, len(zones))
+	for n, z := range zones {
+		name := fmt.Sprintf("%s zone %d", fl.Name, n+1)
+		l = append(l, importedWindow(name, "", "", mapRect(z, ref, work), stateNormal))
+	}
+	return l, nil
+}
+
+// zones returns the zones of a custom layout, within the ref area.
+func (fl *fancyZonesLayout) zones() (zones []rect, ref rect, err error) {
+	switch fl.Type {
+	case "canvas":
+		var c fancyZonesCanvas
+		if err := json.Unmarshal(fl.Info, &c); err != nil {
+			return nil, rect{}, err
+		}
+		if c.RefWidth <= 0 || c.RefHeight <= 0 {
+			return nil, rect{}, fmt.Errorf("invalid reference size %dx%d", c.RefWidth, c.RefHeight)
+		}
+		for _, z := range c.Zones {
+			zones = append(zones, rect{Left: z.X, Top: z.Y, Right: z.X + z.Width, Bottom: z.Y + z.Height})
+		}
+		return zones, rect{Right: c.RefWidth, Bottom: c.RefHeight}, nil
+	case "grid":
+		var g fancyZonesGrid
+		if err := json.Unmarshal(fl.Info, &g); err != nil {
+			return nil, rect{}, err
+		}
+		return g.zones()
+	}
+	return nil, rect{}, fmt.Errorf("unknown type '%s'", fl.Type)
+}
+
+// zones returns the zones of a grid, each one the union of its cells,
+// within a 10000x10000 area.
+func (g *fancyZonesGrid) zones() ([]rect, rect, error) {
+	if len(g.RowsPercentage) != g.Rows || len(g.ColumnsPercentage) != g.Columns || len(g.CellChildMap) != g.Rows {
+		return nil, rect{}, fmt.Errorf("%dx%d grid of inconsistent sizes", g.Rows, g.Columns)
+	}
+	edges := func(percentages []int32) []int32 {
+		e := []int32{0}
+		for _, p := range percentages {
+			e = append(e, e[len(e)-1]+p)
+		}
+		return e
+	}
+	for _, p := range append(append([]int32{}, g.RowsPercentage...), g.ColumnsPercentage...) {
+		if p < 0 {
+			return nil, rect{}, fmt.Errorf("negative percentage %d", p)
+		}
+	}
+	rows, cols := edges(g.RowsPercentage), edges(g.ColumnsPercentage)
+	if cols[len(cols)-1] <= 0 || rows[len(rows)-1] <= 0 {
+		return nil, rect{}, fmt.Errorf("invalid grid size %dx%d", cols[len(cols)-1], rows[len(rows)-1])
+	}
+	var zones []rect
+	for r, cells := range g.CellChildMap {
+		if len(cells) != g.Columns {
+			return nil, rect{}, fmt.Errorf("row %d has %d cells, not %d", r+1, len(cells), g.Columns)
+		}
+		for c, z := range cells {
+			if z < 0 || z > len(zones) {
+				return nil, rect{}, fmt.Errorf("cell %d,%d: zone %d out of order", r+1, c+1, z)
+			}
+			cell := rect{Left: cols[c], Top: rows[r], Right: cols[c+1], Bottom: rows[r+1]}
+			if z == len(zones) {
+				zones = append(zones, cell)
+				continue
+			}
+			zones[z] = rect{Left: min(zones[z].Left, cell.Left), Top: min(zones[z].Top, cell.Top),
+				Right: max(zones[z].Right, cell.Right), Bottom: max(zones[z].Bottom, cell.Bottom)}
+		}
+	}
+	return zones, rect{Right: cols[len(cols)-1], Bottom: rows[len(rows)-1]}, nil
+}
+
+// writeFancyZones writes the windows of each monitor of the layout as the
+// zones of a canvas custom layout, named after the layout and the monitor rank.
+func writeFancyZones(name string, lay *layout) ([]byte, error) {
+	if len(lay.Monitors) == 0 {
+		return nil, fmt.Errorf("layout '%s' records no monitor", name)
+	}
+	canvases := make([]*fancyZonesCanvas, len(lay.Monitors))
+	for _, w := range lay.Windows {
+		p := w.placement()
+		i := monitorIndex(p.Normal, lay.Monitors)
+		work := workArea(lay.Monitors[i])
+		r := p.Normal
+		if p.State == stateMaximized {
+			r = work
+		}
+		if canvases[i] == nil {
+			canvases[i] = &fancyZonesCanvas{RefWidth: work.width(), RefHeight: work.height(), SensitivityRadius: fancyZonesSensitivity}
+		}
+		canvases[i].Zones = append(canvases[i].Zones, fancyZone{X: r.Left - work.Left, Y: r.Top - work.Top, Width: r.width(), Height: r.height()})
+	}
+	fz := fancyZones{CustomLayouts: make([]fancyZonesLayout, 0)}
+	for i, c := range canvases {
+		if c == nil {
+			continue
+		}
+		info, err := json.Marshal(c)
+		if err != nil {
+			return nil, err
+		}
+		uuid, err := newUUID()
+		if err != nil {
+			return nil, err
+		}
+		fz.CustomLayouts = append(fz.CustomLayouts, fancyZonesLayout{UUID: uuid, Name: fmt.Sprintf("%s %d", name, i+1), Type: "canvas", Info: info})
+	}
+	b, err := json.MarshalIndent(fz, "", "  ")
+	return append(b, '\n'), err
+}
+
+// mergeFancyZones merges the custom layouts of b into the file old, keeping
+// its other layouts: a layout of the same name is replaced in place, with its
+// UUID so that the monitors applying it keep it, and the others are appended.
+func mergeFancyZones(old, b []byte) ([]byte, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(old, &doc); err != nil {
+		return nil, err
+	}
+	var fz, added fancyZones
+	if err := json.Unmarshal(old, &fz); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &added); err != nil {
+		return nil, err
+	}
+	if doc == nil || fz.CustomLayouts == nil {
+		return nil, fmt.Errorf("no custom-layouts")
+	}
+	index := make(map[string]int, len(fz.CustomLayouts))
+	for i, fl := range fz.CustomLayouts {
+		index[fl.Name] = i
+	}
+	for _, fl := range added.CustomLayouts {
+		i, ok := index[fl.Name]
+		if !ok {
+			fz.CustomLayouts = append(fz.CustomLayouts, fl)
+			continue
+		}
+		fl.UUID = fz.CustomLayouts[i].UUID
+		fz.CustomLayouts[i] = fl
+	}
+	l, err := json.Marshal(fz.CustomLayouts)
+	if err != nil {
+		return nil, err
+	}
+	doc["custom-layouts"] = l
+	b, err = json.MarshalIndent(doc, "", "  ")
+	return append(b, '\n'), err
+}
+
+// newUUID returns a random UUID, braced as in the FancyZones files.
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("{%X-%X-%X-%X-%X}", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
@@ -0,0 +1,151 @@
+package main
+
+// Windows 10 and later windows have an invisible resize frame on their left,
+// right and bottom sides: a window flush with its work area overflows it by that much.
+const frame = 8
+
+// monitorIndex returns the index of the monitor holding most of r,
+// or the one closest to its center when r is off-screen,
+// or -1 without any monitor.
+func monitorIndex(r rect, monitors []*monitor) int {
+	best, bestArea := -1, int64(0)
+	for i, m := range monitors {
+		if a := intersection(r, m.R); a > bestArea {
+			best, bestArea = i, a
+		}
+	}
+	if best >= 0 {
+		return best
+	}
+	cx, cy := int64(r.Left+r.Right)/2, int64(r.Top+r.Bottom)/2
+	bestDist := int64(-1)
+	for i, m := range monitors {
+		dx := cx - int64(clamp32(int32(cx), m.R.Left, m.R.Right))
+		dy := cy - int64(clamp32(int32(cy), m.R.Top, m.R.Bottom))
+		if d := dx*dx + dy*dy; bestDist < 0 || d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
+func intersection(a, b rect) int64 {
+	w := int64(min(a.Right, b.Right)) - int64(max(a.Left, b.Left))
+	h := int64(min(a.Bottom, b.Bottom)) - int64(max(a.Top, b.Top))
+	if w <= 0 || h <= 0 {
+		return 0
+	}
+	return w * h
+}
+
+// primaryMonitor returns the index of the primary monitor,
+// the first one if none is.
+func primaryMonitor(monitors []*monitor) int {
+	for i, m := range monitors {
+		if m.Primary {
+			return i
+		}
+	}
+	return 0
+}
+
+// workArea is the work area of m, or its bounds for monitors
+// recorded without one.
+func workArea(m *monitor) rect {
+	if m.Work.width() > 0 && m.Work.height() > 0 {
+		return m.Work
+	}
+	return m.R
+}
+
+// targetRect computes where a recorded window goes on the current monitors:
+// its rect is translated and scaled from the work area of its recorded monitor
+// to the one of the corresponding current monitor (unless Restore.Strategy
+// keeps it), resized from the DPI of the one to the DPI of the other if they
+// differ, then clamped into it.
+func (mm *monitorMap) targetRect(r rect) rect {
+	if len(mm.current) == 0 {
+		return r
+	}
+	i := monitorIndex(r, mm.recorded)
+	if i < 0 {
+		// No monitor recorded: keep the rect, on the screen it is on now.
+		return clampRect(r, workArea(mm.current[monitorIndex(r, mm.current)]))
+	}
+	fromM, toM := mm.recorded[i], mm.current[mm.to[i]]
+	from, to := workArea(fromM), workArea(toM)
+	w, h := r.width(), r.height()
+	if from != to && conf.Restore.Strategy == strategyScale {
+		r = mapRect(r, from, to)
+	}
+	// Keep the window the same size in logical pixels, rather than
+	// proportional to its monitor.
+	if fromM.DPI != toM.DPI && fromM.DPI > 0 && toM.DPI > 0 {
+		r.Right = r.Left + rescale(w, fromM.DPI, toM.DPI)
+		r.Bottom = r.Top + rescale(h, fromM.DPI, toM.DPI)
+	}
+	return clampRect(r, to)
+}
+
+// targetPlacement computes the placement of a recorded window on the current
+// monitors. The normal position, and the snapped one if any, are each mapped
+// from their monitor, so that a maximized or minimized window is restored
+// onto the right monitor.
+func (mm *monitorMap) targetPlacement(p placement) placement {
+	to := p
+	to.Normal = mm.targetRect(p.Normal)
+	if p.snapped() {
+		to.R = mm.targetRect(p.R)
+	} else {
+		to.R = to.Normal
+	}
+	return to
+}
+
+// rescale converts a length in physical pixels from one DPI to another.
+func rescale(v int32, from, to uint32) int32 {
+	return int32(int64(v) * int64(to) / int64(from))
+}
+
+// scale is the scaling factor of a DPI, in percent.
+func scale(dpi uint32) uint32 {
+	return dpi * 100 / 96
+}
+
+// mapRect proportionally maps r, relative to the from area, into the to area.
+// An empty from area, as hand-edited layouts may record, has no proportions:
+// r is only translated with it.
+func mapRect(r, from, to rect) rect {
+	if from.width() <= 0 || from.height() <= 0 {
+		dx, dy := to.Left-from.Left, to.Top-from.Top
+		return rect{Left: r.Left + dx, Top: r.Top + dy, Right: r.Right + dx, Bottom: r.Bottom + dy}
+	}
+	sx := func(x int32) int32 {
+		return to.Left + int32(int64(x-from.Left)*int64(to.width())/int64(from.width()))
+	}
+	sy := func(y int32) int32 {
+		return to.Top + int32(int64(y-from.Top)*int64(to.height())/int64(from.height()))
+	}
+	return rect{Left: sx(r.Left), Top: sy(r.Top), Right: sx(r.Right), Bottom: sy(r.Bottom)}
+}
+
+// clampRect shrinks and moves r into the work area, give or take the
+// invisible frame on the left, right and bottom sides, so that its title bar
+// is always visible: its top never goes above the work area.
+func clampRect(r, work rect) rect {
+	w, h := r.width(), r.height()
+	if maxw := work.width() + 2*frame; w > maxw {
+		w = maxw
+	}
+	if maxh := work.height() + frame; h > maxh {
+		h = maxh
+	}
+	left := clamp32(r.Left, work.Left-frame, work.Right+frame-w)
+	top := clamp32(r.Top, work.Top, work.Bottom+frame-h)
+	return rect{Left: left, Top: top, Right: left + w, Bottom: top + h}
+}
+
+// clamp32 returns v within [lo, hi], or lo when hi < lo.
+func clamp32(v, lo, hi int32) int32 {
+	return max(lo, min(v, hi))
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestClampRect(t *testing.T) {
+	work := rect{0, 0, 1920, 1040}
+	tests := []struct {
+		name    string
+		r, want rect
+	}{
+		{"inside", rect{100, 100, 900, 700}, rect{100, 100, 900, 700}},
+		{"flush with the frame", rect{-8, 0, 1928, 1048}, rect{-8, 0, 1928, 1048}},
+		{"above", rect{100, -300, 900, 300}, rect{100, 0, 900, 600}},
+		{"taller than the work area", rect{100, -300, 900, 1500}, rect{100, 0, 900, 1048}},
+		{"wider than the work area", rect{-100, 0, 2100, 500}, rect{-8, 0, 1928, 500}},
+		{"off the left", rect{-500, 100, 300, 700}, rect{-8, 100, 792, 700}},
+		{"off the right", rect{1800, 100, 2600, 700}, rect{1128, 100, 1928, 700}},
+		{"off the bottom", rect{100, 900, 900, 1500}, rect{100, 448, 900, 1048}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := clampRect(tt.r, work); got != tt.want {
+				t.Errorf("clampRect(%s) = %s, want %s", tt.r, got, tt.want)
+			}
+		})
+	}
+	// on a monitor left of the primary one
+	if got, want := clampRect(rect{-100, -50, 500, 400}, rect{-1280, 0, 0, 984}), (rect{-592, 0, 8, 450}); got != want {
+		t.Errorf("clampRect on the left monitor = %s, want %s", got, want)
+	}
+}
+
+func TestMapRect(t *testing.T) {
+	from, to := rect{0, 0, 1920, 1040}, rect{1920, 0, 4480, 1400}
+	tests := []struct {
+		r, want rect
+	}{
+		{from, to},
+		{rect{960, 520, 1920, 1040}, rect{3200, 700, 4480, 1400}},
+		{rect{0, 0, 480, 260}, rect{1920, 0, 2560, 350}},
+	}
+	for _, tt := range tests {
+		if got := mapRect(tt.r, from, to); got != tt.want {
+			t.Errorf("mapRect(%s) = %s, want %s", tt.r, got, tt.want)
+		}
+	}
+	// an empty area, of a hand-edited layout, only translates
+	if got, want := mapRect(rect{100, 100, 900, 700}, rect{0, 0, 0, 1040}, to), (rect{2020, 100, 2820, 700}); got != want {
+		t.Errorf("mapRect from an empty area = %s, want %s", got, want)
+	}
+}
+
+func TestMonitorIndex(t *testing.T) {
+	tests := []struct {
+		r    rect
+		want int
+	}{
+		{rect{100, 100, 900, 700}, 0},
+		{rect{1500, 100, 2500, 700}, 1}, // mostly on the second one
+		{rect{5000, 100, 5800, 700}, 1}, // off-screen, closest to the second one
+		{rect{-900, 100, -100, 700}, 0},
+	}
+	for _, tt := range tests {
+		if got := monitorIndex(tt.r, testMonitors()); got != tt.want {
+			t.Errorf("monitorIndex(%s) = %d, want %d", tt.r, got, tt.want)
+		}
+	}
+	if got := monitorIndex(rect{0, 0, 10, 10}, nil); got != -1 {
+		t.Errorf("monitorIndex without monitor = %d, want -1", got)
+	}
+}
+
+func TestTargetRect(t *testing.T) {
+	laptop := []*monitor{{Device: `\\.\DISPLAY1`, R: rect{0, 0, 1920, 1080}, Work: rect{0, 0, 1920, 1040}, DPI: 96, Primary: true}}
+	large := []*monitor{{Device: `\\.\DISPLAY1`, R: rect{0, 0, 2560, 1440}, Work: rect{0, 0, 2560, 1400}, DPI: 96, Primary: true}}
+	tests := []struct {
+		name     string
+		recorded []*monitor
+		r, want  rect
+	}{
+		{"scaled", laptop, rect{0, 0, 960, 520}, rect{0, 0, 1280, 700}},
+		{"same monitor", large, rect{0, 0, 960, 520}, rect{0, 0, 960, 520}},
+		{"same monitor, clamped", large, rect{2000, 1200, 2800, 1800}, rect{1768, 808, 2568, 1408}},
+		{"no monitor recorded", nil, rect{100, -100, 900, 500}, rect{100, 0, 900, 600}},
+		{"empty monitor recorded", []*monitor{{Device: `\\.\DISPLAY1`, DPI: 96}}, rect{100, 100, 900, 700}, rect{100, 100, 900, 700}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newMonitorMap(&layout{Monitors: tt.recorded}, large).targetRect(tt.r); got != tt.want {
+				t.Errorf("targetRect(%s) = %s, want %s", tt.r, got, tt.want)
+			}
+		})
+	}
+	// the recorded second monitor, by device name, moved left of the first one
+	moved := testMonitors()
+	moved[1].R, moved[1].Work = rect{-1920, 0, 0, 1080}, rect{-1920, 0, 0, 1040}
+	if got, want := newMonitorMap(&layout{Monitors: testMonitors()}, moved).targetRect(rect{2000, 100, 2800, 700}), (rect{-1840, 100, -1040, 700}); got != want {
+		t.Errorf("targetRect on the moved monitor = %s, want %s", got, want)
+	}
+}
+
+func TestTargetPlacement(t *testing.T) {
+	mm := newMonitorMap(&layout{Monitors: testMonitors()}, testMonitors())
+	snapped := placement{State: stateNormal, Normal: rect{100, 100, 900, 700}, R: rect{-8, 0, 968, 1048}}
+	if got := mm.targetPlacement(snapped); got != snapped {
+		t.Errorf("snapped placement moved to %+v", got)
+	}
+	normal := placement{State: stateNormal, Normal: rect{100, -100, 900, 500}, R: rect{100, -100, 900, 500}}
+	if got := mm.targetPlacement(normal); got.R != got.Normal || got.Normal != (rect{100, 0, 900, 600}) {
+		t.Errorf("normal placement moved to %+v, want clamped, not snapped", got)
+	}
+}
+
+func TestTargetRectDPI(t *testing.T) {
+	at := func(dpi uint32) []*monitor {
+		return []*monitor{{Device: `\\.\DISPLAY1`, R: rect{0, 0, 2560, 1440}, Work: rect{0, 0, 2560, 1400}, DPI: dpi, Primary: true}}
+	}
+	tests := []struct {
+		from, to uint32
+		r, want  rect
+	}{
+		{96, 144, rect{100, 100, 900, 700}, rect{100, 100, 1300, 1000}},
+		{144, 96, rect{100, 100, 1300, 1000}, rect{100, 100, 900, 700}},
+		{96, 96, rect{100, 100, 900, 700}, rect{100, 100, 900, 700}},
+		{0, 144, rect{100, 100, 900, 700}, rect{100, 100, 900, 700}},  // DPI not recorded
+		{96, 192, rect{100, 100, 1500, 800}, rect{-8, 8, 2568, 1408}}, // clamped
+	}
+	for _, tt := range tests {
+		mm := newMonitorMap(&layout{Monitors: at(tt.from)}, at(tt.to))
+		if got := mm.targetRect(tt.r); got != tt.want {
+			t.Errorf("targetRect(%s) from %d to %d DPI = %s, want %s", tt.r, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestScale(t *testing.T) {
+	for dpi, want := range map[uint32]uint32{96: 100, 120: 125, 144: 150, 192: 200} {
+		if got := scale(dpi); got != want {
+			t.Errorf("scale(%d) = %d%%, want %d%%", dpi, got, want)
+		}
+	}
+}
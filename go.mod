@@ -1,6 +1,11 @@
 module winpos
 
+go 1.21
+
 require (
+	github.com/BurntSushi/toml v1.5.0
 	github.com/lxn/win v0.0.0-20190508144640-5d15a47a4bff
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
 	golang.org/x/sys v0.1.0
+	gopkg.in/yaml.v3 v3.0.1
 )
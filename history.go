@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// snapshotTime names the snapshot files, in UTC, without ':' for Windows.
+const snapshotTime = "2006-01-02T15-04-05.000Z"
+
+// snapshot is a past version of a layout.
+type snapshot struct {
+	Time time.Time
+	path string
+}
+
+func (s *store) historyDir(name string) string {
+	return filepath.Join(s.dir, "history", name)
+}
+
+// saveSnapshot saves lay as the snapshot of the layout at time t,
+// in the History.Format setting, then prunes the older snapshots.
+func (s *store) saveSnapshot(name string, t time.Time, lay *layout) error {
+	dir := s.historyDir(name)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	if err := Save(filepath.Join(dir, t.UTC().Format(snapshotTime)+codecByName(conf.History.Format).ext()), lay); err != nil {
+		return err
+	}
+	return s.prune(name, t)
+}
+
+// history returns the snapshots of a layout, the latest first.
+func (s *store) history(name string) ([]snapshot, error) {
+	if _, err := s.path(name); err != nil {
+		return nil, err
+	}
+	dir := s.historyDir(name)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return make([]snapshot, 0), nil
+		}
+		return nil, err
+	}
+	l := make([]snapshot, 0, len(entries))
+	for _, e := range entries {
+		base, c := splitExt(e.Name())
+		t, err := time.Parse(snapshotTime, base)
+		if e.IsDir() || c == nil || err != nil {
+			continue
+		}
+		l = append(l, snapshot{Time: t, path: filepath.Join(dir, e.Name())})
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i].Time.After(l[j].Time) })
+	return l, nil
+}
+
+// prune keeps the History.Keep most recent snapshots, no older than
+// History.MaxAge. The latest snapshot is always kept.
+func (s *store) prune(name string, now time.Time) error {
+	l, err := s.history(name)
+	if err != nil {
+		return err
+	}
+	for i, sn := range l {
+		if i == 0 || i < conf.History.Keep && now.Sub(sn.Time) <= time.Duration(conf.History.MaxAge) {
+			continue
+		}
+		if err := os.Remove(sn.path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapshotAt returns the snapshot of a layout designated by at:
+// either its rank N in the history (0 being the latest),
+// or a date and time, for the latest snapshot at or before it.
+func (s *store) snapshotAt(name, at string) (snapshot, error) {
+	l, err := s.history(name)
+	if err != nil {
+		return snapshot{}, err
+	}
+	if len(l) == 0 {
+		return snapshot{}, fmt.Errorf("no history for layout '%s'", name)
+	}
+	if n, err := strconv.Atoi(at); err == nil {
+		if n < 0 || n >= len(l) {
+			return snapshot{}, fmt.Errorf("layout '%s' has %d snapshot(s), no snapshot %d", name, len(l), n)
+		}
+		return l[n], nil
+	}
+	t, err := parseTime(at)
+	if err != nil {
+		return snapshot{}, err
+	}
+	for _, sn := range l {
+		if !sn.Time.After(t) {
+			return sn, nil
+		}
+	}
+	return snapshot{}, fmt.Errorf("no snapshot of layout '%s' at or before %s", name, t.Format(time.RFC3339))
+}
+
+var timeLayouts = []string{
+	time.RFC3339,
+	snapshotTime,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseTime parses a snapshot date and time, local unless a zone is given.
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid snapshot '%s': use a rank (0 for the latest) or a date like 2006-01-02 15:04:05", s)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// historyStore is a store holding the layout "work" with a snapshot each
+// minute from 10:00 UTC, the window of each moved 100 pixels right.
+func historyStore(t *testing.T, snapshots int) (*store, time.Time) {
+	t.Helper()
+	st := &store{dir: t.TempDir()}
+	start := time.Date(2024, 5, 6, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < snapshots; i++ {
+		w := &window{Name: "a", Class: "A", R: rect{int32(100 * i), 0, int32(100*i + 800), 600}}
+		if err := st.saveSnapshot("work", start.Add(time.Duration(i)*time.Minute), newLayout(testMonitors(), []*window{w})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return st, start
+}
+
+func TestPrune(t *testing.T) {
+	setConfig(t, func(c *config) {
+		c.History.Keep = 3
+		c.History.MaxAge = duration(time.Hour)
+	})
+	st, start := historyStore(t, 5)
+	l, err := st.history("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 3 || !l[0].Time.Equal(start.Add(4*time.Minute)) || !l[2].Time.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("history %v, want the last 3 snapshots, latest first", l)
+	}
+	// an hour later, only the latest one is left
+	if err := st.prune("work", start.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if l, _ = st.history("work"); len(l) != 1 || !l[0].Time.Equal(start.Add(4*time.Minute)) {
+		t.Errorf("history %v, want the latest snapshot only", l)
+	}
+}
+
+func TestSnapshotAt(t *testing.T) {
+	st, start := historyStore(t, 3)
+	tests := []struct {
+		at   string
+		want time.Time
+		err  string
+	}{
+		{"0", start.Add(2 * time.Minute), ""},
+		{"2", start, ""},
+		{"3", time.Time{}, "has 3 snapshot(s), no snapshot 3"},
+		{"-1", time.Time{}, "no snapshot -1"},
+		{"2024-05-06T10:01:30Z", start.Add(time.Minute), ""},
+		{"2024-05-06T10-01-00.000Z", start.Add(time.Minute), ""},
+		{"2024-05-06T09:59:59Z", time.Time{}, "no snapshot of layout 'work' at or before"},
+		{"yesterday", time.Time{}, "invalid snapshot 'yesterday'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.at, func(t *testing.T) {
+			sn, err := st.snapshotAt("work", tt.at)
+			switch {
+			case tt.err != "":
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("error %v, want %q", err, tt.err)
+				}
+			case err != nil:
+				t.Error(err)
+			case !sn.Time.Equal(tt.want):
+				t.Errorf("snapshot of %s, want %s", sn.Time, tt.want)
+			}
+		})
+	}
+	if _, err := st.snapshotAt("home", "0"); err == nil || !strings.Contains(err.Error(), "no history for layout 'home'") {
+		t.Errorf("error %v for a layout without history", err)
+	}
+}
+
+func TestLoadAt(t *testing.T) {
+	st, _ := historyStore(t, 3)
+	var lay layout
+	if err := st.loadAt("work", "1", &lay); err != nil {
+		t.Fatal(err)
+	}
+	if want := (rect{100, 0, 900, 600}); len(lay.Windows) != 1 || lay.Windows[0].R != want {
+		t.Errorf("snapshot 1 holds %v, want a window at %s", lay.Windows, want)
+	}
+}
+
+func TestSaveSnapshots(t *testing.T) {
+	st := &store{dir: t.TempDir()}
+	lay := newLayout(testMonitors(), []*window{{Name: "a", Class: "A", R: rect{0, 0, 800, 600}}})
+	for i := 0; i < 2; i++ {
+		if err := st.save("work", lay); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l, err := st.history("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 1 {
+		t.Fatalf("%d snapshot(s) of an unchanged layout saved twice, want 1", len(l))
+	}
+	if !strings.HasSuffix(l[0].path, gobCodec.ext()) {
+		t.Errorf("snapshot %s, want it in the History.Format", l[0].path)
+	}
+	// files of other tools in the history are ignored
+	if err := os.WriteFile(filepath.Join(st.historyDir("work"), "notes.txt"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if l, _ = st.history("work"); len(l) != 1 {
+		t.Errorf("%d snapshot(s), want the one saved", len(l))
+	}
+	if err := st.delete("work"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(st.historyDir("work")); !os.IsNotExist(err) {
+		t.Errorf("history left after delete: %v", err)
+	}
+}
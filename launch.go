@@ -0,0 +1,117 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"time"
+)
+
+// launchPoll is the interval between two looks for the windows of the
+// launched applications.
+const launchPoll = 250 * time.Millisecond
+
+// launchable tells whether the application of a recorded window
+// can be started again.
+func launchable(w *window) bool {
+	return w.Exe != "" || w.AppID != ""
+}
+
+// launchesApp tells whether the application of a window is started from its
+// AppUserModelID rather than from its executable: only packaged applications,
+// installed under WindowsApps, are. Desktop applications like Chrome set an
+// explicit AppUserModelID too, which the shell may not know how to start.
+func launchesApp(w *window) bool {
+	return w.AppID != "" && (w.Exe == "" || strings.Contains(strings.ToLower(w.Exe), `\windowsapps\`))
+}
+
+// launchKey identifies the launches of an application: windows of the same
+// command line come from a single launch.
+func launchKey(w *window) string {
+	return w.AppID + "|" + w.Exe + "|" + w.Cmdline + "|" + w.Dir
+}
+
+// launchMissing starts the applications of the unmatched windows,
+// waits up to timeout for their windows to appear, and adds them to the plan.
+// It records each command started in p.launched, for the window it was started for.
+func (p *restorePlan) launchMissing(ws WindowSystem, timeout time.Duration) error {
+	waiting := make([]*window, 0)
+	launched := make(map[string]bool)
+	p.launched = make(map[*window]string)
+	for _, w := range p.Unmatched {
+		if !launchable(w) {
+			continue
+		}
+		waiting = append(waiting, w)
+		if launched[launchKey(w)] {
+			continue
+		}
+		launched[launchKey(w)] = true
+		if err := ws.Launch(w); err != nil {
+			log.Printf("Winpos restore: cannot launch '%s': %v", w.Name, err)
+			continue
+		}
+		p.launched[w] = launchCommand(w)
+	}
+	if len(waiting) == 0 {
+		return nil
+	}
+
+	found := make(map[*window]bool)
+	for deadline := time.Now().Add(timeout); ; {
+		live, err := listWindows(ws)
+		if err != nil {
+			return err
+		}
+		matched, _, err := matchWindows(waiting, p.unused(live))
+		if err != nil {
+			return err
+		}
+		for _, m := range matched {
+			p.addMove(ws, m)
+			found[m.Saved] = true
+		}
+		waiting = remaining(waiting, found)
+		if len(waiting) == 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(launchPoll)
+	}
+	p.Unmatched = remaining(p.Unmatched, found)
+	return nil
+}
+
+// unused returns the live windows not moved by the plan yet.
+func (p *restorePlan) unused(live []*window) []*window {
+	used := make(map[hwnd]bool)
+	for _, mv := range p.Moves {
+		used[mv.Live.Hwnd] = true
+	}
+	l := make([]*window, 0, len(live))
+	for _, w := range live {
+		if !used[w.Hwnd] {
+			l = append(l, w)
+		}
+	}
+	return l
+}
+
+func remaining(l []*window, found map[*window]bool) []*window {
+	r := make([]*window, 0, len(l))
+	for _, w := range l {
+		if !found[w] {
+			r = append(r, w)
+		}
+	}
+	return r
+}
+
+// launchCommand describes what Launch starts for a window.
+func launchCommand(w *window) string {
+	switch {
+	case launchesApp(w):
+		return "app " + w.AppID
+	case w.Cmdline != "":
+		return w.Cmdline
+	}
+	return w.Exe
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestLaunchCommand(t *testing.T) {
+	const calc = "Microsoft.WindowsCalculator_8wekyb3d8bbwe!App"
+	tests := []struct {
+		name string
+		w    *window
+		want string
+	}{
+		{"executable", &window{Exe: `C:\Windows\notepad.exe`}, `C:\Windows\notepad.exe`},
+		{"command line", &window{Exe: `C:\Windows\notepad.exe`, Cmdline: `notepad.exe C:\a.txt`}, `notepad.exe C:\a.txt`},
+		{"application", &window{AppID: calc}, "app " + calc},
+		{"packaged application", &window{AppID: calc, Exe: `C:\Program Files\WindowsApps\Microsoft.WindowsCalculator_11.2210.0.0_x64__8wekyb3d8bbwe\CalculatorApp.exe`}, "app " + calc},
+		{"desktop application with an AppID", &window{AppID: "Chrome", Exe: `C:\Program Files\Google\Chrome\Application\chrome.exe`, Cmdline: "chrome.exe --profile-directory=Default"},
+			"chrome.exe --profile-directory=Default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := launchCommand(tt.w); got != tt.want {
+				t.Errorf("launchCommand = %s, want %s", got, tt.want)
+			}
+		})
+	}
+	if launchable(&window{Name: "a", Class: "A"}) {
+		t.Errorf("window without executable nor AppID launchable")
+	}
+}
+
+func TestRestoreLaunchMissing(t *testing.T) {
+	d, lay := restoreDesktop()
+	paint := &window{Name: "Paint", Class: "MSPaintApp", Exe: `C:\Windows\mspaint.exe`, R: rect{200, 150, 1000, 750}}
+	// a second window of the same command, launched once
+	paint2 := &window{Name: "Paint", Class: "MSPaintApp", Exe: `C:\Windows\mspaint.exe`, R: rect{300, 150, 1100, 750}}
+	unknown := &window{Name: "Unknown", Class: "Unknown", R: rect{0, 0, 100, 100}}
+	lay.Windows = append(lay.Windows, paint, paint2, unknown)
+	st := &store{dir: t.TempDir()}
+	if err := st.save("work", lay); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restore(d, st, "work", restoreOptions{launchMissing: true}); err != nil {
+		t.Fatal(err)
+	}
+	launched := 0
+	for _, fw := range d.Wins {
+		if fw.Name == "Paint" {
+			launched++
+			if fw.R != paint.R {
+				t.Errorf("launched window at %s, want %s", fw.R, paint.R)
+			}
+		}
+	}
+	if launched != 1 {
+		t.Errorf("%d Paint window(s) launched, want 1", launched)
+	}
+	if len(d.Wins) != 4 {
+		t.Errorf("%d windows, want the Unknown one not launched", len(d.Wins))
+	}
+}
+
+func TestRestoreLaunchDryRun(t *testing.T) {
+	d, lay := restoreDesktop()
+	lay.Windows = append(lay.Windows, &window{Name: "Paint", Class: "MSPaintApp", Exe: `C:\Windows\mspaint.exe`, R: rect{200, 150, 1000, 750}})
+	st := &store{dir: t.TempDir()}
+	if err := st.save("work", lay); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restore(d, st, "work", restoreOptions{launchMissing: true, dryRun: true}); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Wins) != 3 {
+		t.Errorf("dry run launched %d window(s)", len(d.Wins)-3)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// layout is what a layout file holds: the monitors and windows
+// at the time of the record. See layout.schema.json.
+type layout struct {
+	Version  int // layoutVersion
+	Created  time.Time
+	Host     string `json:",omitempty"`
+	User     string `json:",omitempty"`
+	Monitors []*monitor
+	Windows  []*window
+	// MonitorMap maps recorded monitors to current ones, for the explicit
+	// mapping strategy: see findMonitor.
+	MonitorMap map[string]string `json:",omitempty"`
+}
+
+// topology tells how the current monitors compare to recorded ones.
+type topology int
+
+const (
+	topologySame    topology = iota // same monitors, same geometry
+	topologyChanged                 // same number of monitors, but moved, resized or rescaled
+	topologyMissing                 // fewer monitors than recorded
+	topologyUnknown                 // no monitor recorded
+)
+
+func (t topology) String() string {
+	switch t {
+	case topologySame:
+		return "same monitors"
+	case topologyChanged:
+		return "monitors changed"
+	case topologyMissing:
+		return "monitors missing"
+	}
+	return "no monitor recorded"
+}
+
+func (t topology) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// compareTopology compares the recorded monitors to the current ones.
+// It returns a description of the first difference found, if any.
+func compareTopology(recorded, current []*monitor) (topology, string) {
+	if len(recorded) == 0 {
+		return topologyUnknown, ""
+	}
+	if len(current) < len(recorded) {
+		return topologyMissing, fmt.Sprintf("%d monitor(s) recorded, %d connected", len(recorded), len(current))
+	}
+	if len(current) > len(recorded) {
+		return topologyChanged, fmt.Sprintf("%d monitor(s) recorded, %d connected", len(recorded), len(current))
+	}
+	for i, r := range recorded {
+		c := current[i]
+		switch {
+		case r.Device != c.Device:
+			return topologyChanged, fmt.Sprintf("monitor %d is %s, was %s", i+1, c.Device, r.Device)
+		case r.R != c.R:
+			return topologyChanged, fmt.Sprintf("monitor %d (%s) is %s, was %s", i+1, c.Device, c.R, r.R)
+		case r.DPI != c.DPI:
+			return topologyChanged, fmt.Sprintf("monitor %d (%s) is at %d DPI, was %d", i+1, c.Device, c.DPI, r.DPI)
+		case r.Primary != c.Primary:
+			return topologyChanged, fmt.Sprintf("monitor %d (%s) primary flag changed", i+1, c.Device)
+		}
+	}
+	return topologySame, ""
+}
+
+// fingerprint identifies a set of monitors: their device, bounds, DPI
+// and primary flag, whatever the order they are enumerated in.
+func fingerprint(monitors []*monitor) string {
+	l := make([]string, 0, len(monitors))
+	for _, m := range monitors {
+		l = append(l, fmt.Sprintf("%s|%d,%d,%d,%d|%d|%t", m.Device,
+			m.R.Left, m.R.Top, m.R.Right, m.R.Bottom, m.DPI, m.Primary))
+	}
+	sort.Strings(l)
+	sum := sha1.Sum([]byte(strings.Join(l, ";")))
+	return hex.EncodeToString(sum[:4])
+}
+
+// topologyScore rates, from 0 to 100, how close the current monitors
+// are to recorded ones. Only the same number of monitors can score.
+func topologyScore(recorded, current []*monitor) int {
+	if len(recorded) == 0 || len(recorded) != len(current) {
+		return 0
+	}
+	if fingerprint(recorded) == fingerprint(current) {
+		return 100
+	}
+	const perMonitor = 45 // device 10 + bounds 20 + size 10 + DPI 5
+	total := 0
+	used := make(map[int]bool)
+	for _, r := range recorded {
+		best, bestc := 0, -1
+		for ci, c := range current {
+			if used[ci] {
+				continue
+			}
+			s := 0
+			if r.Device == c.Device {
+				s += 10
+			}
+			if r.R == c.R {
+				s += 20
+			}
+			if r.R.width() == c.R.width() && r.R.height() == c.R.height() {
+				s += 10
+			}
+			if r.DPI == c.DPI {
+				s += 5
+			}
+			if bestc < 0 || s > best {
+				best, bestc = s, ci
+			}
+		}
+		used[bestc] = true
+		total += best
+	}
+	// Keep 100 for an exact fingerprint match.
+	return total * 99 / (perMonitor * len(recorded))
+}
+
+// minTopologyScore is the score under which no layout is auto-selected.
+const minTopologyScore = 50
+
+// selectLayout returns the stored layout recorded with the monitors
+// closest to the current ones, the most recent one on a tie.
+// Layouts which cannot be loaded are skipped, and listed as unreadable
+// when none is selected.
+func selectLayout(st *store, monitors []*monitor) (string, error) {
+	stored, err := st.list()
+	if err != nil {
+		return "", err
+	}
+	type candidate struct {
+		storedLayout
+		score int
+	}
+	candidates := make([]candidate, 0, len(stored))
+	unreadable := make([]string, 0)
+	for _, sl := range stored {
+		var lay layout
+		if err := st.load(sl.Name, &lay); err != nil {
+			debugf("Winpos restore: layout '%s' skipped: %v", sl.Name, err)
+			unreadable = append(unreadable, sl.Name)
+			continue
+		}
+		candidates = append(candidates, candidate{sl, topologyScore(lay.Monitors, monitors)})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		ci, cj := candidates[i], candidates[j]
+		if ci.score != cj.score {
+			return ci.score > cj.score
+		}
+		return ci.Modified.After(cj.Modified)
+	})
+	if len(candidates) > 0 && candidates[0].score >= minTopologyScore {
+		return candidates[0].Name, nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "no layout recorded with the %d connected monitor(s) (fingerprint %s)",
+		len(monitors), fingerprint(monitors))
+	for _, c := range candidates {
+		fmt.Fprintf(&b, "\n  %-30s topology score %d%%", c.Name, c.score)
+	}
+	for _, name := range unreadable {
+		fmt.Fprintf(&b, "\n  %-30s unreadable", name)
+	}
+	return "", errors.New(b.String())
+}
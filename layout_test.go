@@ -0,0 +1,134 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompareTopology(t *testing.T) {
+	moved := testMonitors()
+	moved[1].R = rect{1920, 200, 3840, 1280}
+	rescaled := testMonitors()
+	rescaled[1].DPI = 96
+	renamed := testMonitors()
+	renamed[0].Device = `\\.\DISPLAY5`
+	primary := testMonitors()
+	primary[0].Primary = true
+	tests := []struct {
+		name              string
+		recorded, current []*monitor
+		want              topology
+		diff              string
+	}{
+		{"same", testMonitors(), testMonitors(), topologySame, ""},
+		{"none recorded", nil, testMonitors(), topologyUnknown, ""},
+		{"missing", testMonitors(), testMonitors()[:1], topologyMissing, "2 monitor(s) recorded, 1 connected"},
+		{"added", testMonitors()[:1], testMonitors(), topologyChanged, "1 monitor(s) recorded, 2 connected"},
+		{"moved", testMonitors(), moved, topologyChanged, `monitor 2 (\\.\DISPLAY2) is (1920,200) 1920x1080, was (1920,0) 1920x1080`},
+		{"rescaled", testMonitors(), rescaled, topologyChanged, `monitor 2 (\\.\DISPLAY2) is at 96 DPI, was 144`},
+		{"renamed", testMonitors(), renamed, topologyChanged, `monitor 1 is \\.\DISPLAY5, was \\.\DISPLAY1`},
+		{"primary", testMonitors(), primary, topologyChanged, `monitor 1 (\\.\DISPLAY1) primary flag changed`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, diff := compareTopology(tt.recorded, tt.current)
+			if got != tt.want || diff != tt.diff {
+				t.Errorf("%s (%s), want %s (%s)", got, diff, tt.want, tt.diff)
+			}
+		})
+	}
+}
+
+func TestRecordMonitors(t *testing.T) {
+	st := &store{dir: t.TempDir()}
+	d := testDesktop(&fakeWindow{Hwnd: 1, Name: "a", Visible: true})
+	if _, err := record(d, st, "work", nil); err != nil {
+		t.Fatal(err)
+	}
+	var lay layout
+	if err := st.load("work", &lay); err != nil {
+		t.Fatal(err)
+	}
+	if topo, diff := compareTopology(lay.Monitors, testMonitors()); topo != topologySame || len(lay.Windows) != 1 {
+		t.Errorf("recorded %s (%s), %d window(s): want the same monitors and 1 window", topo, diff, len(lay.Windows))
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	m := testMonitors()
+	if fingerprint(m) != fingerprint([]*monitor{m[1], m[0]}) {
+		t.Errorf("fingerprint depends on the order of the monitors")
+	}
+	if fingerprint(m) == fingerprint(m[:1]) {
+		t.Errorf("same fingerprint for one and two monitors")
+	}
+	if f := fingerprint(m); len(f) != 8 || strings.Trim(f, "0123456789abcdef") != "" {
+		t.Errorf("fingerprint %s is not 8 hexadecimal digits", f)
+	}
+}
+
+func TestTopologyScore(t *testing.T) {
+	m := testMonitors()
+	moved := testMonitors()
+	moved[1].R = rect{1920, 200, 3840, 1280}
+	other := []*monitor{mon("X", 0, 0, 1280, 800), mon("Y", 1280, 0, 1280, 800)}
+	tests := []struct {
+		name              string
+		recorded, current []*monitor
+		want              int
+	}{
+		{"same", m, testMonitors(), 100},
+		{"same, listed in another order", m, []*monitor{m[1], m[0]}, 100},
+		{"moved", m, moved, 77},
+		{"other monitors", m, other, 5},
+		{"fewer", m, m[:1], 0},
+		{"none recorded", nil, m, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := topologyScore(tt.recorded, tt.current); got != tt.want {
+				t.Errorf("score %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectLayout(t *testing.T) {
+	st := &store{dir: t.TempDir()}
+	save := func(name string, monitors []*monitor, modified time.Time) {
+		t.Helper()
+		if err := st.save(name, newLayout(monitors, []*window{})); err != nil {
+			t.Fatal(err)
+		}
+		path, _ := st.path(name)
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	save("laptop", testMonitors()[:1], now)
+	save("docked", testMonitors(), now.Add(-time.Hour))
+	save("docked-new", testMonitors(), now.Add(-time.Minute))
+	if err := os.WriteFile(filepath.Join(st.dir, "broken.json"), []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// the most recent of the best scores, the broken layout skipped
+	if name, err := selectLayout(st, testMonitors()); err != nil || name != "docked-new" {
+		t.Errorf("selected %s, %v, want docked-new", name, err)
+	}
+	if name, err := selectLayout(st, testMonitors()[:1]); err != nil || name != "laptop" {
+		t.Errorf("selected %s, %v, want laptop", name, err)
+	}
+	_, err := selectLayout(st, []*monitor{mon("X", 0, 0, 1280, 800)})
+	if err == nil {
+		t.Fatal("selected a layout of other monitors")
+	}
+	for _, want := range []string{"no layout recorded with the 1 connected monitor(s)", "laptop", "topology score", "broken", "unreadable"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q, without %q", err, want)
+		}
+	}
+}
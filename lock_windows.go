@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// fileLock is an exclusive lock on a file, held across processes.
+type fileLock struct {
+	f *os.File
+}
+
+// lockFile waits for an exclusive lock on the file at path, created if needed.
+func lockFile(path string) (*fileLock, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	var ol windows.Overlapped
+	if err := windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0, &ol); err != nil {
+		f.Close()
+		return nil, &os.PathError{Op: "LockFileEx", Path: path, Err: err}
+	}
+	return &fileLock{f: f}, nil
+}
+
+func (l *fileLock) unlock() error {
+	var ol windows.Overlapped
+	err := windows.UnlockFileEx(windows.Handle(l.f.Fd()), 0, 1, 0, &ol)
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// syncDir is a no-op on Windows, which cannot flush a directory:
+// NTFS journals the rename itself.
+func syncDir(dir string) error {
+	return nil
+}
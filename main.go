@@ -1,210 +1,264 @@
-package main
-
-import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"io"
-	"log"
-	"os"
-	"sync"
-	"syscall"
-	"unsafe"
-
-	"github.com/lxn/win"
-	"golang.org/x/sys/windows"
-)
-
-var (
-	libuser32               *windows.LazyDLL
-	procGetWindowTextW      *windows.LazyProc
-	procEnumDisplayMonitors *windows.LazyProc
-	procEnumWindows         *windows.LazyProc
-)
-
-func init() {
-	// Library
-	libuser32 = windows.NewLazySystemDLL("user32.dll")
-	procGetWindowTextW = libuser32.NewProc("GetWindowTextW")
-	procEnumDisplayMonitors = libuser32.NewProc("EnumDisplayMonitors")
-	procEnumWindows = libuser32.NewProc("EnumWindows")
-}
-
-func main() {
-	ndisplays := numActiveDisplays()
-	// fmt.Printf("numActiveDisplays='%d'\n", ndisplays)
-	argsWithoutProg := os.Args[1:]
-
-	if len(argsWithoutProg) != 1 {
-		fmt.Printf("Usage: winpos [record|restore]\n")
-		return
-	}
-	if argsWithoutProg[0] == "record" {
-		if ndisplays <= 1 {
-			fmt.Printf("Winpos record: only 1 screen, nothing to record\n")
-			return
-		}
-		record()
-	}
-	if argsWithoutProg[0] == "restore" {
-		if ndisplays <= 1 {
-			fmt.Printf("Winpos restore: only 1 screen, nothing to restore\n")
-			return
-		}
-		restore()
-	}
-}
-
-func record() {
-	hwnd := win.GetDesktopWindow()
-	hdc := win.GetDC(hwnd)
-	if hdc == 0 {
-		log.Fatalf("GetDC failed")
-	}
-	defer win.ReleaseDC(hwnd, hdc)
-	l := listWindows(win.HWND(0))
-	if err := Save("./file.tmp", &l); err != nil {
-		log.Fatalln(err)
-	}
-}
-
-func restore() {
-	// load it back
-	var ll []*window
-	if err := Load("./file.tmp", &ll); err != nil {
-		log.Fatalln(err)
-	}
-	for i := range ll {
-		w := ll[len(ll)-i-1]
-		win.MoveWindow(w.Hwnd, w.R.Left, w.R.Top, w.R.Right-w.R.Left, w.R.Bottom-w.R.Top, true)
-		win.SetForegroundWindow(w.Hwnd)
-		win.BringWindowToTop(w.Hwnd)
-		if w.Maximize {
-			win.ShowWindow(w.Hwnd, win.SW_MAXIMIZE)
-		}
-		win.SetFocus(w.Hwnd)
-	}
-}
-
-type window struct {
-	Hwnd        win.HWND
-	Name, Class string
-	R           win.RECT
-	visible     bool
-	Maximize    bool
-	hasChild    bool
-	Style       int32
-	Caption     bool
-}
-
-func listWindows(hwnd win.HWND) []*window {
-	l := make([]*window, 0)
-	perWindow := func(hwnd win.HWND, param uintptr) uintptr {
-		// https://go101.org/article/unsafe.html
-		w := window{Hwnd: hwnd}
-		w.visible = win.IsWindowVisible(hwnd)
-		win.GetWindowRect(hwnd, &w.R)
-		w.Name = getName(hwnd, procGetWindowTextW)
-		w.hasChild = win.GetWindow(hwnd, win.GW_CHILD) != 0
-		w.Style = win.GetWindowLong(hwnd, win.GWL_STYLE)
-		// https://stackoverflow.com/questions/21503109/how-to-use-enumwindows-to-get-only-actual-application-windows
-		w.Maximize = ((w.Style & 0x01000000) == 0x01000000)
-		w.Caption = ((w.Style & 0x10C00000) == 0x10C00000)
-		if w.Caption && w.visible && w.Name != "" {
-			l = append(l, &w)
-		}
-		return 1
-	}
-	_, _, _ = syscall.Syscall(procEnumWindows.Addr(), 2,
-		windows.NewCallback(perWindow), 0, 0)
-	return l
-}
-
-const bufSiz = 128 // Max length I want to see
-func getName(hwnd win.HWND, get *windows.LazyProc) string {
-	var buf [bufSiz]uint16
-	siz, _, _ := get.Call(uintptr(hwnd), uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
-	if siz == 0 {
-		return ""
-	}
-	name := syscall.UTF16ToString(buf[:siz])
-	if siz == bufSiz-1 {
-		name = name + "\u22EF"
-	}
-	return name
-}
-
-// https://github.com/kbinani/screenshot/blob/9ef8b9209e372fbb0c126cc2648e33bece0c9660/screenshot_windows.go
-func numActiveDisplays() int {
-	var count int
-	countupMonitorCallback := func(hMonitor win.HMONITOR, hdcMonitor win.HDC, lprcMonitor *win.RECT, dwData uintptr) uintptr {
-		count = count + 1
-		return uintptr(1)
-	}
-	enumDisplayMonitors(win.HDC(0), nil, syscall.NewCallback(countupMonitorCallback), uintptr(unsafe.Pointer(&count)))
-	return count
-}
-
-func enumDisplayMonitors(hdc win.HDC, lprcClip *win.RECT, lpfnEnum uintptr, dwData uintptr) bool {
-	ret, _, _ := syscall.Syscall6(procEnumDisplayMonitors.Addr(), 4,
-		uintptr(hdc),
-		uintptr(unsafe.Pointer(lprcClip)),
-		lpfnEnum,
-		dwData,
-		0,
-		0)
-	return int(ret) != 0
-}
-
-// https://medium.com/@matryer/golang-advent-calendar-day-eleven-persisting-go-objects-to-disk-7caf1ee3d11d
-
-// Marshal is a function that marshals the object into an
-// io.Reader.
-// By default, it uses the JSON marshaller.
-var Marshal = func(v interface{}) (io.Reader, error) {
-	b, err := json.MarshalIndent(v, "", "\t")
-	if err != nil {
-		return nil, err
-	}
-	return bytes.NewReader(b), nil
-}
-
-var lock sync.Mutex
-
-// Save saves a representation of v to the file at path.
-func Save(path string, v interface{}) error {
-	lock.Lock()
-	defer lock.Unlock()
-	f, err := os.Create(path)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	r, err := Marshal(v)
-	if err != nil {
-		return err
-	}
-	_, err = io.Copy(f, r)
-	return err
-}
-
-// Unmarshal is a function that unmarshals the data from the
-// reader into the specified value.
-// By default, it uses the JSON unmarshaller.
-var Unmarshal = func(r io.Reader, v interface{}) error {
-	return json.NewDecoder(r).Decode(v)
-}
-
-// Load loads the file at path into v.
-// Use os.IsNotExist() to see if the returned error is due
-// to the file being missing.
-func Load(path string, v interface{}) error {
-	lock.Lock()
-	defer lock.Unlock()
-	f, err := os.Open(path)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	return Unmarshal(f, v)
-}
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const usage = `Usage: winpos [<global flags>] <command> [<layout>] [<flags>]
+
+Commands:
+  record [<layout>]   record the windows position in the layout
+  restore [<layout>]  restore the windows position recorded in the layout
+      --auto          restore the layout recorded with the monitors now connected
+      --at <N|date>   restore a snapshot of the layout: its rank in the history
+                      (0 for the latest), or the latest one at or before a date
+      --dry-run       print what would be restored, without moving any window
+      --launch-missing
+                      start the applications of the recorded windows not running
+      --launch-timeout
+                      time to wait for their windows (Restore.LaunchTimeout, 30s)
+      --mapping <strategies>
+                      how recorded monitors are paired with the current ones
+                      (Restore.Mapping, explicit,device,position)
+  diff [<layout>]     compare the layout to the current windows
+      --at <N|date>   compare a snapshot of the layout
+  watch               snapshot the windows for each set of monitors, and restore
+                      them automatically when monitors are plugged or unplugged
+      --interval      time between two snapshots (Watch.Interval, 1m)
+      --debounce      time the monitors must be stable before restoring
+                      (Watch.Debounce, 5s)
+  list                list the recorded layouts
+  show <layout>       show the windows recorded in the layout
+  history [<layout>]  list the snapshots of the layout, the latest first
+  delete <layout>     delete the layout and its history
+  migrate [<file>] [<layout>]
+                      save a layout file of an older winpos version (default:
+                      the file.tmp of the first versions) as a layout
+  schema              print the JSON Schema of the layout files
+  import <file> [<layout>]
+                      save the windows of a file of another tool as a layout
+      --as <format>   csv or fancyzones (default: by the file extension,
+                      .csv or .json)
+      --zones <name>  FancyZones custom layout to import, if several
+      --monitor <N>   rank of the monitor the zones go on (default: primary)
+  export <file> [<layout>]
+                      write the windows of the layout for another tool
+      --as <format>   csv or fancyzones, as for import
+  edit <layout> <action>
+                      edit the windows of the layout meeting <criteria> (see
+                      below), checked against the layout schema:
+      set <criteria> <field>=<value>...
+                      set title, class, exe, cmdline, appid, dir, regex (title
+                      regexp) or state (normal, minimized or maximized)
+      move <criteria> <x>,<y> [<width>x<height>]
+                      move them, in virtual screen coordinates
+      remove <criteria>
+                      remove them
+      monitor <criteria> <N>
+                      move them to the monitor of rank N, scaled to its size
+      snap <criteria> <fraction> [<N>]
+                      snap them to a fraction of their monitor, or of monitor
+                      N: full, left-half, right-half, top-half, bottom-half,
+                      top-left, top-right, bottom-left, bottom-right,
+                      left-third, middle-third, right-third, left-two-thirds
+                      or right-two-thirds
+      rename <name>   rename the layout, with its history
+
+  config show         show the configuration in effect
+  config validate     check the configuration file
+  config init         create the configuration file, with the default settings
+      --force         overwrite it
+
+<layout> defaults to the DefaultLayout setting ('default').
+
+Global flags, overriding the configuration file:
+  --config <file>     configuration file (default: config.json in the winpos
+                      directory of the user configuration directory)
+  --store <dir>       layouts directory
+  --log <file>        log file
+  --verbose           verbose logs
+  --output <format>   text (default), json or yaml output of record, restore,
+                      diff, list, show, history, migrate, import, export
+                      and edit
+  --format <format>   json, yaml, toml or gob format of the layouts written
+                      (Format, json)
+
+Exit codes: 0 success, 1 failure, 2 invalid arguments, 3 partial success
+(restore: some recorded windows could not be restored).
+
+record, restore, diff and watch select the windows with the rules file,
+overridden by:
+  --only <criteria>     only the windows meeting the criteria (repeatable)
+  --exclude <criteria>  not the windows meeting the criteria (repeatable)
+<criteria> are comma-separated exe:<glob>, class:<glob>, title:<glob>,
+regex:<title regexp> or monitor:<rank>, like exe:chrome.exe,title:*Jira*
+`
+
+// errUsage reports command line arguments which do not make sense.
+var errUsage = errors.New("invalid arguments")
+
+func main() {
+	g, argsWithoutProg, err := parseGlobalFlags(os.Args[1:])
+	if err != nil || len(argsWithoutProg) < 1 {
+		fmt.Print(usage)
+		os.Exit(exitUsage)
+	}
+	cmd, args := argsWithoutProg[0], argsWithoutProg[1:]
+	// config reports an invalid configuration file itself.
+	if err := g.apply(); err != nil && cmd != "config" {
+		log.Fatalln(err)
+	} else if err != nil {
+		g.err = err
+	}
+	st, err := defaultStore()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fs := flag.NewFlagSet("winpos "+cmd, flag.ExitOnError)
+	fs.Usage = func() { fmt.Fprint(fs.Output(), usage) }
+	var res result
+	switch cmd {
+	case "config":
+		err = cmdConfig(g, fs, args)
+	case "record":
+		res, err = cmdRecord(st, fs, args)
+	case "restore":
+		res, err = cmdRestore(st, fs, args)
+	case "diff":
+		res, err = cmdDiff(st, fs, args)
+	case "watch":
+		err = cmdWatch(st, fs, args)
+	case "list":
+		res, err = cmdList(st, fs, args)
+	case "show":
+		res, err = cmdShow(st, fs, args)
+	case "history":
+		res, err = cmdHistory(st, fs, args)
+	case "delete":
+		res, err = cmdDelete(st, fs, args)
+	case "migrate":
+		res, err = cmdMigrate(st, fs, args)
+	case "schema":
+		err = cmdSchema(fs, args)
+	case "import":
+		res, err = cmdImport(st, fs, args)
+	case "export":
+		res, err = cmdExport(st, fs, args)
+	case "edit":
+		res, err = cmdEdit(st, fs, args)
+	default:
+		err = errUsage
+	}
+	if err == errUsage {
+		fs.Usage()
+		os.Exit(exitUsage)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if res != nil {
+		if err := writeResult(os.Stdout, g.output, res); err != nil {
+			log.Fatalln(err)
+		}
+		os.Exit(exitCode(res))
+	}
+}
+
+// parseArgs parses the flags wherever they are among args,
+// and returns the other arguments.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	pos := make([]string, 0)
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return pos, nil
+		}
+		pos = append(pos, args[0])
+		args = args[1:]
+	}
+}
+
+// layoutArg parses args for commands taking a layout name,
+// optional unless required.
+func layoutArg(fs *flag.FlagSet, args []string, required bool) (string, error) {
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return "", err
+	}
+	return layoutName(pos, required)
+}
+
+func layoutName(pos []string, required bool) (string, error) {
+	switch {
+	case len(pos) > 1, len(pos) == 0 && required:
+		return "", errUsage
+	case len(pos) == 0:
+		return conf.DefaultLayout, nil
+	}
+	return pos[0], nil
+}
+
+// lock serializes Save and Load within the process,
+// see store.lock for the other processes.
+var lock sync.Mutex
+
+// Save saves a representation of v to the file at path, in the format of
+// its extension (see codecOf), atomically: to a temporary file flushed
+// to disk, then renamed over path.
+// A crash or a full disk leaves the previous file intact.
+func Save(path string, v interface{}) error {
+	b, err := codecOf(path).marshal(v)
+	if err != nil {
+		return err
+	}
+	return writeFile(path, b)
+}
+
+// writeFile writes b to the file at path, atomically, like Save.
+func writeFile(path string, b []byte) error {
+	lock.Lock()
+	defer lock.Unlock()
+	dir := filepath.Dir(path)
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // once renamed, there is nothing left to remove
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// Load loads the file at path into v, in the format of its extension.
+// Use os.IsNotExist() to see if the returned error is due
+// to the file being missing.
+func Load(path string, v interface{}) error {
+	lock.Lock()
+	b, err := os.ReadFile(path)
+	lock.Unlock()
+	if err != nil {
+		return err
+	}
+	return codecOf(path).unmarshal(b, v)
+}
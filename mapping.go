@@ -0,0 +1,247 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// monitorMap tells which current monitor stands for each recorded one.
+type monitorMap struct {
+	recorded, current []*monitor
+	to                []int // index in current of each recorded monitor, -1 if none
+	collapsed         int   // recorded monitors missing, and collapsed onto others
+	explicit          map[string]string
+}
+
+// mappingStrategy pairs recorded monitors with current ones. It only pairs
+// the monitors it can tell apart, and leaves the others to the next strategy.
+type mappingStrategy func(mm *monitorMap)
+
+// Monitor mapping strategies, as listed in Restore.Mapping.
+var mappingStrategies = map[string]mappingStrategy{
+	"explicit":   mapExplicit,
+	"device":     mapDevice,
+	"position":   mapPosition,
+	"resolution": mapResolution,
+	"rank":       mapRank,
+}
+
+// mappingNames lists the mapping strategies, for messages.
+func mappingNames() string {
+	l := make([]string, 0, len(mappingStrategies))
+	for name := range mappingStrategies {
+		l = append(l, name)
+	}
+	sort.Strings(l)
+	return strings.Join(l, ", ")
+}
+
+// newMonitorMap pairs the monitors of lay with the current ones, applying
+// the Restore.Mapping strategies in turn. Recorded monitors left over, when there are fewer
+// monitors now, are collapsed according to Restore.Collapse.
+func newMonitorMap(lay *layout, current []*monitor) *monitorMap {
+	mm := &monitorMap{recorded: lay.Monitors, current: current,
+		to: make([]int, len(lay.Monitors)), explicit: lay.MonitorMap}
+	for i := range mm.to {
+		mm.to[i] = -1
+	}
+	for _, name := range conf.Restore.Mapping {
+		if s := mappingStrategies[name]; s != nil {
+			s(mm)
+		}
+	}
+	for i, r := range mm.recorded {
+		if mm.to[i] < 0 && len(current) > 0 {
+			mm.to[i] = collapseMonitor(r, current)
+			mm.collapsed++
+		}
+	}
+	return mm
+}
+
+// used tells whether a current monitor is already paired.
+func (mm *monitorMap) used(j int) bool {
+	for _, t := range mm.to {
+		if t == j {
+			return true
+		}
+	}
+	return false
+}
+
+// pairIf pairs each unpaired recorded monitor with the first unpaired
+// current one for which same is true.
+func (mm *monitorMap) pairIf(same func(r, c *monitor) bool) {
+	for i, r := range mm.recorded {
+		if mm.to[i] >= 0 {
+			continue
+		}
+		for j, c := range mm.current {
+			if !mm.used(j) && same(r, c) {
+				mm.to[i] = j
+				break
+			}
+		}
+	}
+}
+
+// pairClosest pairs the unpaired monitors, the closest pairs first.
+func (mm *monitorMap) pairClosest(dist func(r, c *monitor) float64) {
+	type pair struct {
+		i, j int
+		d    float64
+	}
+	var pairs []pair
+	for i, r := range mm.recorded {
+		if mm.to[i] >= 0 {
+			continue
+		}
+		for j, c := range mm.current {
+			if !mm.used(j) {
+				pairs = append(pairs, pair{i, j, dist(r, c)})
+			}
+		}
+	}
+	sort.SliceStable(pairs, func(a, b int) bool { return pairs[a].d < pairs[b].d })
+	for _, p := range pairs {
+		if mm.to[p.i] < 0 && !mm.used(p.j) {
+			mm.to[p.i] = p.j
+		}
+	}
+}
+
+// mapExplicit applies the MonitorMap of the layout, from a recorded monitor
+// (its rank, device name, device ID or serial) to a current one (same).
+// Its entries are applied in the order of their keys: of two entries
+// targeting the same current monitor, the first one wins.
+func mapExplicit(mm *monitorMap) {
+	froms := make([]string, 0, len(mm.explicit))
+	for from := range mm.explicit {
+		froms = append(froms, from)
+	}
+	sort.Strings(froms)
+	for _, from := range froms {
+		i, j := findMonitor(from, mm.recorded), findMonitor(mm.explicit[from], mm.current)
+		if i >= 0 && j >= 0 && mm.to[i] < 0 && !mm.used(j) {
+			mm.to[i] = j
+		}
+	}
+}
+
+// findMonitor returns the index of the monitor designated by its rank
+// (from 1), device name, device ID or serial, -1 if none.
+func findMonitor(id string, monitors []*monitor) int {
+	if n, err := strconv.Atoi(id); err == nil {
+		if n >= 1 && n <= len(monitors) {
+			return n - 1
+		}
+		return -1
+	}
+	for i, m := range monitors {
+		if strings.EqualFold(id, m.Device) || strings.EqualFold(id, m.DeviceID) || id == m.Serial {
+			return i
+		}
+	}
+	return -1
+}
+
+// mapDevice pairs the same physical monitors: same model and EDID serial,
+// else same device ID. Monitors recorded without a device ID are paired by
+// GDI device name.
+func mapDevice(mm *monitorMap) {
+	mm.pairIf(func(r, c *monitor) bool {
+		return r.Serial != "" && r.Serial == c.Serial && hardwareID(r.DeviceID) == hardwareID(c.DeviceID)
+	})
+	mm.pairIf(func(r, c *monitor) bool {
+		return r.DeviceID != "" && strings.EqualFold(r.DeviceID, c.DeviceID)
+	})
+	mm.pairIf(func(r, c *monitor) bool {
+		return r.DeviceID == "" && r.Device != "" && r.Device == c.Device
+	})
+}
+
+// hardwareID returns the manufacturer and product code of a monitor
+// device ID, like DEL4091 in \\?\DISPLAY#DEL4091#5&2b3c4d&0&UID4353#{...}
+func hardwareID(deviceID string) string {
+	parts := strings.Split(deviceID, "#")
+	if len(parts) < 2 {
+		return ""
+	}
+	return strings.ToUpper(parts[1])
+}
+
+// mapPosition pairs the monitors at the same relative place in their
+// arrangement: the leftmost recorded one with the leftmost current one...
+func mapPosition(mm *monitorMap) {
+	from, to := bounds(mm.recorded), bounds(mm.current)
+	mm.pairClosest(func(r, c *monitor) float64 {
+		rx, ry := relativeCenter(r.R, from)
+		cx, cy := relativeCenter(c.R, to)
+		return math.Hypot(rx-cx, ry-cy)
+	})
+}
+
+// bounds is the rect holding all the monitors.
+func bounds(monitors []*monitor) rect {
+	var b rect
+	for i, m := range monitors {
+		if i == 0 {
+			b = m.R
+			continue
+		}
+		b = rect{Left: min(b.Left, m.R.Left), Top: min(b.Top, m.R.Top),
+			Right: max(b.Right, m.R.Right), Bottom: max(b.Bottom, m.R.Bottom)}
+	}
+	return b
+}
+
+// relativeCenter is the center of r, from 0 to 1 across b.
+func relativeCenter(r, b rect) (float64, float64) {
+	rel := func(c, lo, size int32) float64 {
+		if size <= 0 {
+			return 0.5
+		}
+		return (float64(c) - float64(lo)) / float64(size)
+	}
+	return rel((r.Left+r.Right)/2, b.Left, b.width()), rel((r.Top+r.Bottom)/2, b.Top, b.height())
+}
+
+// mapResolution pairs the monitors of closest resolution, then DPI.
+func mapResolution(mm *monitorMap) {
+	mm.pairClosest(func(r, c *monitor) float64 {
+		d := math.Abs(math.Log(float64(r.R.width())*float64(r.R.height())) -
+			math.Log(float64(c.R.width())*float64(c.R.height())))
+		if r.DPI != c.DPI {
+			d += 0.01
+		}
+		return d
+	})
+}
+
+// mapRank pairs the remaining monitors in the order they are enumerated.
+func mapRank(mm *monitorMap) {
+	j := 0
+	for i := range mm.recorded {
+		if mm.to[i] >= 0 {
+			continue
+		}
+		for j < len(mm.current) && mm.used(j) {
+			j++
+		}
+		if j < len(mm.current) {
+			mm.to[i] = j
+			j++
+		}
+	}
+}
+
+// collapseMonitor returns the current monitor taking the windows
+// of a missing one: the primary one, or the closest one.
+func collapseMonitor(missing *monitor, current []*monitor) int {
+	if conf.Restore.Collapse == collapsePrimary {
+		return primaryMonitor(current)
+	}
+	return monitorIndex(missing.R, current)
+}
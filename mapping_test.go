@@ -0,0 +1,172 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// mon is a monitor of the given device name and bounds, at 96 DPI.
+func mon(device string, left, top, width, height int32) *monitor {
+	r := rect{left, top, left + width, top + height}
+	return &monitor{Device: device, R: r, Work: r, DPI: 96}
+}
+
+func TestMonitorMapStrategies(t *testing.T) {
+	withID := func(m *monitor, id, serial string) *monitor {
+		m.DeviceID, m.Serial = id, serial
+		return m
+	}
+	tests := []struct {
+		name     string
+		mapping  string
+		explicit map[string]string
+		recorded []*monitor
+		current  []*monitor
+		want     []int
+	}{
+		{
+			"explicit ranks, the first key wins", "explicit,rank",
+			map[string]string{"1": "2", "2": "2"},
+			[]*monitor{mon("A", 0, 0, 1920, 1080), mon("B", 1920, 0, 1920, 1080)},
+			[]*monitor{mon("A", 0, 0, 1920, 1080), mon("B", 1920, 0, 1920, 1080)},
+			[]int{1, 0},
+		},
+		{
+			"explicit devices and serials", "explicit",
+			map[string]string{`\\.\display2`: "SER1", "SER2": `\\.\DISPLAY2`, "9": "1"},
+			[]*monitor{withID(mon(`\\.\DISPLAY1`, 0, 0, 1920, 1080), "", "SER2"), mon(`\\.\DISPLAY2`, 1920, 0, 1920, 1080)},
+			[]*monitor{withID(mon(`\\.\DISPLAY1`, 0, 0, 1920, 1080), "", "SER1"), mon(`\\.\DISPLAY2`, 1920, 0, 1920, 1080)},
+			[]int{1, 0},
+		},
+		{
+			"device by serial, on another port", "device",
+			nil,
+			[]*monitor{
+				withID(mon(`\\.\DISPLAY1`, 0, 0, 1920, 1080), `\\?\DISPLAY#DEL4091#5&1&0&UID1#{e6f07b5f}`, "SER1"),
+				withID(mon(`\\.\DISPLAY2`, 1920, 0, 1920, 1080), `\\?\DISPLAY#DEL4091#5&1&0&UID2#{e6f07b5f}`, "SER2"),
+			},
+			[]*monitor{
+				withID(mon(`\\.\DISPLAY1`, 0, 0, 1920, 1080), `\\?\DISPLAY#DEL4091#5&1&0&UID3#{e6f07b5f}`, "SER2"),
+				withID(mon(`\\.\DISPLAY2`, 1920, 0, 1920, 1080), `\\?\DISPLAY#DEL4091#5&1&0&UID4#{e6f07b5f}`, "SER1"),
+			},
+			[]int{1, 0},
+		},
+		{
+			"device by ID, then by name", "device",
+			nil,
+			[]*monitor{withID(mon(`\\.\DISPLAY1`, 0, 0, 1920, 1080), `\\?\DISPLAY#SAM0F9C#4&2#{e6f07b5f}`, ""), mon(`\\.\DISPLAY3`, 1920, 0, 1920, 1080)},
+			[]*monitor{mon(`\\.\DISPLAY3`, 0, 0, 1920, 1080), withID(mon(`\\.\DISPLAY2`, 1920, 0, 1920, 1080), `\\?\display#sam0f9c#4&2#{e6f07b5f}`, "")},
+			[]int{1, 0},
+		},
+		{
+			"position", "position",
+			nil,
+			[]*monitor{mon("A", 0, 0, 1920, 1080), mon("B", 1920, 0, 1920, 1080)},
+			[]*monitor{mon("B", 0, 0, 2560, 1440), mon("A", -1920, 200, 1920, 1080)},
+			[]int{1, 0},
+		},
+		{
+			"resolution", "resolution",
+			nil,
+			[]*monitor{mon("A", 0, 0, 3840, 2160), mon("B", 3840, 0, 1920, 1080)},
+			[]*monitor{mon("C", 0, 0, 1920, 1200), mon("D", 1920, 0, 3840, 2160)},
+			[]int{1, 0},
+		},
+		{
+			"rank", "rank",
+			nil,
+			[]*monitor{mon("A", 0, 0, 1920, 1080), mon("B", 1920, 0, 1920, 1080)},
+			[]*monitor{mon("C", 1920, 0, 1920, 1080), mon("D", 0, 0, 1920, 1080)},
+			[]int{0, 1},
+		},
+		{
+			"strategies in turn", "device,rank",
+			nil,
+			[]*monitor{mon("A", 0, 0, 1920, 1080), mon("B", 1920, 0, 1920, 1080), mon("C", 3840, 0, 1920, 1080)},
+			[]*monitor{mon("X", 0, 0, 1920, 1080), mon("C", 1920, 0, 1920, 1080), mon("Y", 3840, 0, 1920, 1080)},
+			[]int{0, 2, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setConfig(t, func(c *config) { c.Restore.Mapping = strings.Split(tt.mapping, ",") })
+			mm := newMonitorMap(&layout{Monitors: tt.recorded, MonitorMap: tt.explicit}, tt.current)
+			if !reflect.DeepEqual(mm.to, tt.want) || mm.collapsed != 0 {
+				t.Errorf("mapped to %v, %d collapsed, want %v", mm.to, mm.collapsed, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindMonitor(t *testing.T) {
+	monitors := []*monitor{mon(`\\.\DISPLAY1`, 0, 0, 1920, 1080), mon(`\\.\DISPLAY2`, 1920, 0, 1920, 1080)}
+	monitors[1].DeviceID, monitors[1].Serial = `\\?\DISPLAY#DEL4091#5&1#{e6f07b5f}`, "SER2"
+	tests := map[string]int{
+		"1":                                  0,
+		"2":                                  1,
+		"3":                                  -1,
+		"0":                                  -1,
+		`\\.\display1`:                       0,
+		`\\?\display#del4091#5&1#{e6f07b5f}`: 1,
+		"SER2":                               1,
+		"ser2":                               -1,
+		`\\.\DISPLAY3`:                       -1,
+	}
+	for id, want := range tests {
+		if got := findMonitor(id, monitors); got != want {
+			t.Errorf("findMonitor(%s) = %d, want %d", id, got, want)
+		}
+	}
+}
+
+func TestHardwareID(t *testing.T) {
+	if got := hardwareID(`\\?\DISPLAY#del4091#5&2b3c4d&0&UID4353#{e6f07b5f}`); got != "DEL4091" {
+		t.Errorf("hardwareID = %s, want DEL4091", got)
+	}
+	if got := hardwareID(""); got != "" {
+		t.Errorf("hardwareID of no device ID = %s", got)
+	}
+}
+
+func TestMonitorMapCollapse(t *testing.T) {
+	recorded := []*monitor{mon("A", -1920, 0, 1920, 1080), mon("B", 0, 0, 1920, 1080), mon("C", 1920, 0, 1920, 1080)}
+	current := []*monitor{mon("B", 0, 0, 1920, 1080), mon("C", 1920, 0, 1920, 1080)}
+	current[1].Primary = true
+	tests := []struct {
+		collapse string
+		want     []int
+	}{
+		{collapseNearest, []int{0, 0, 1}},
+		{collapsePrimary, []int{1, 0, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.collapse, func(t *testing.T) {
+			setConfig(t, func(c *config) { c.Restore.Collapse = tt.collapse })
+			mm := newMonitorMap(&layout{Monitors: recorded}, current)
+			if !reflect.DeepEqual(mm.to, tt.want) || mm.collapsed != 1 {
+				t.Errorf("mapped to %v, %d collapsed, want %v, 1 collapsed", mm.to, mm.collapsed, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestoreCollapsed(t *testing.T) {
+	d, lay := restoreDesktop()
+	d.Displays = d.Displays[:1] // undocked from the second monitor
+	st := &store{dir: t.TempDir()}
+	if err := st.save("work", lay); err != nil {
+		t.Fatal(err)
+	}
+	r, err := restore(d, st, "work", restoreOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Topology != topologyMissing || len(r.Warnings) != 1 || !strings.Contains(r.Warnings[0], "the windows of 1 monitor(s) are collapsed") {
+		t.Errorf("topology %s, warnings %q: want the second monitor collapsed", r.Topology, r.Warnings)
+	}
+	// b, maximized on the second monitor, is maximized on the first one
+	if _, b, _ := d.find(2); !b.Maximize || b.R != (rect{0, 0, 1920, 1040}) {
+		t.Errorf("b at %s, maximized %t: want maximized on the first monitor", b.R, b.Maximize)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Points given to each piece of evidence that a saved window and
+// a live one are the same application window.
+const (
+	scoreExe         = 30
+	scoreClass       = 20
+	scoreTitle       = 40 // exact title
+	scoreTitleRegex  = 35 // title matching the saved TitleRegex
+	scoreTitlePrefix = 25 // one title is a prefix of the other, or they share most of it
+	scoreTitleApp    = 10 // same application part ("... - Notepad")
+	scoreHwnd        = 10 // same handle, only meaningful within the same session
+
+	// minScore is the confidence below which a pairing is discarded.
+	minScore = 40
+)
+
+// match pairs a saved window with the live window it is restored onto.
+type match struct {
+	Saved  *window
+	Live   *window
+	Score  int
+	Reason string
+}
+
+// matchWindows pairs each saved window with at most one live window,
+// best scores first.
+// It returns the pairings, in saved order, and the saved windows
+// without any live counterpart.
+func matchWindows(saved, live []*window) ([]match, []*window, error) {
+	regexes := make(map[*window]*regexp.Regexp)
+	for _, s := range saved {
+		if s.TitleRegex == "" {
+			continue
+		}
+		re, err := regexp.Compile(s.TitleRegex)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid TitleRegex for '%s': %v", s.Name, err)
+		}
+		regexes[s] = re
+	}
+
+	type candidate struct {
+		match
+		si, li int
+	}
+	candidates := make([]candidate, 0)
+	for si, s := range saved {
+		for li, l := range live {
+			score, reason := matchScore(s, l, regexes[s])
+			if score >= minScore {
+				candidates = append(candidates, candidate{match{s, l, score, reason}, si, li})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+
+	pairs := make(map[int]match)
+	usedLive := make(map[int]bool)
+	for _, c := range candidates {
+		if _, done := pairs[c.si]; done || usedLive[c.li] {
+			continue
+		}
+		pairs[c.si] = c.match
+		usedLive[c.li] = true
+	}
+
+	matched := make([]match, 0, len(pairs))
+	unmatched := make([]*window, 0)
+	for si, s := range saved {
+		if m, ok := pairs[si]; ok {
+			matched = append(matched, m)
+		} else {
+			unmatched = append(unmatched, s)
+		}
+	}
+	return matched, unmatched, nil
+}
+
+// matchScore rates how likely live is the saved window.
+// A known executable, class or title regex which differs rules it out.
+func matchScore(saved, live *window, re *regexp.Regexp) (int, string) {
+	score := 0
+	reasons := make([]string, 0)
+	add := func(points int, reason string) {
+		score += points
+		reasons = append(reasons, reason)
+	}
+
+	if saved.Exe != "" && live.Exe != "" {
+		if !strings.EqualFold(saved.Exe, live.Exe) {
+			return 0, ""
+		}
+		add(scoreExe, "exe")
+	}
+	if saved.Class != "" && live.Class != "" {
+		if saved.Class != live.Class {
+			return 0, ""
+		}
+		add(scoreClass, "class")
+	}
+	switch {
+	case re != nil:
+		if !re.MatchString(live.Name) {
+			return 0, ""
+		}
+		add(scoreTitleRegex, "title regex")
+	case saved.Name == live.Name:
+		add(scoreTitle, "title")
+	case titlePrefix(saved.Name, live.Name):
+		add(scoreTitlePrefix, "title prefix")
+	case titleApp(saved.Name) != "" && titleApp(saved.Name) == titleApp(live.Name):
+		add(scoreTitleApp, "title app")
+	}
+	if saved.Hwnd != 0 && saved.Hwnd == live.Hwnd {
+		add(scoreHwnd, "hwnd")
+	}
+	return score, strings.Join(reasons, "+")
+}
+
+// titlePrefix is true when one title starts with the other,
+// or when both share at least the first half of the shortest one.
+func titlePrefix(a, b string) bool {
+	ra, rb := []rune(a), []rune(b)
+	n := len(ra)
+	if len(rb) < n {
+		n = len(rb)
+	}
+	if n == 0 {
+		return false
+	}
+	common := 0
+	for common < n && ra[common] == rb[common] {
+		common++
+	}
+	return common == n || common*2 >= n && common >= 8
+}
+
+// titleApp returns the application part of a "document - application" title.
+func titleApp(title string) string {
+	i := strings.LastIndex(title, " - ")
+	if i < 0 {
+		return ""
+	}
+	return title[i+3:]
+}
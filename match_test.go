@@ -0,0 +1,172 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestMatchScore(t *testing.T) {
+	notepad := &window{Hwnd: 1, Name: "a.txt - Notepad", Class: "Notepad", Exe: `C:\Windows\notepad.exe`}
+	tests := []struct {
+		name   string
+		saved  *window
+		regex  string
+		score  int
+		reason string
+	}{
+		{"same window", &window{Hwnd: 1, Name: "a.txt - Notepad", Class: "Notepad", Exe: `C:\WINDOWS\NOTEPAD.EXE`}, "",
+			scoreExe + scoreClass + scoreTitle + scoreHwnd, "exe+class+title+hwnd"},
+		{"other exe", &window{Name: "a.txt - Notepad", Class: "Notepad", Exe: `C:\Apps\notepad++.exe`}, "", 0, ""},
+		{"other class", &window{Name: "a.txt - Notepad", Class: "Edit"}, "", 0, ""},
+		{"unknown exe", &window{Name: "a.txt - Notepad", Class: "Notepad"}, "", scoreClass + scoreTitle, "class+title"},
+		{"title prefix", &window{Name: "a.txt", Class: "Notepad"}, "", scoreClass + scoreTitlePrefix, "class+title prefix"},
+		{"title app", &window{Name: "b.txt - Notepad", Class: "Notepad"}, "", scoreClass + scoreTitleApp, "class+title app"},
+		{"other title", &window{Name: "Calculator", Class: "Notepad"}, "", scoreClass, "class"},
+		{"title regex", &window{Name: "b.txt - Notepad", Class: "Notepad"}, `^\w+\.txt - Notepad$`, scoreClass + scoreTitleRegex, "class+title regex"},
+		{"other title regex", &window{Name: "a.txt - Notepad", Class: "Notepad"}, `^\w+\.md - Notepad$`, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var re *regexp.Regexp
+			if tt.regex != "" {
+				re = regexp.MustCompile(tt.regex)
+			}
+			score, reason := matchScore(tt.saved, notepad, re)
+			if score != tt.score || reason != tt.reason {
+				t.Errorf("score %d (%s), want %d (%s)", score, reason, tt.score, tt.reason)
+			}
+		})
+	}
+}
+
+func TestTitlePrefix(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"Inbox", "Inbox (3)", true},
+		{"Inbox (3)", "Inbox", true},
+		{"Project report.docx - Word", "Project report.doc - Word", true},
+		{"a.txt", "b.txt", false},
+		{"short one", "short two", false}, // common part too short
+		{"", "anything", false},
+	}
+	for _, tt := range tests {
+		if got := titlePrefix(tt.a, tt.b); got != tt.want {
+			t.Errorf("titlePrefix(%q, %q) = %t, want %t", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestMatchWindows(t *testing.T) {
+	term := func(h hwnd, name string) *window { return &window{Hwnd: h, Name: name, Class: "Term", Exe: "term.exe"} }
+	tests := []struct {
+		name      string
+		saved     []*window
+		live      []*window
+		want      map[int]hwnd // live window of each saved one, by index
+		unmatched []int
+	}{
+		{
+			"best score first",
+			[]*window{term(0, "build - Term"), term(0, "logs - Term")},
+			[]*window{term(7, "logs - Term"), term(8, "build - Term")},
+			map[int]hwnd{0: 8, 1: 7}, nil,
+		},
+		{
+			"higher score wins over saved order",
+			// the first saved window would take the only live one with
+			// its application part, but the second one has the same title
+			[]*window{term(0, "build - Term"), term(0, "logs - Term")},
+			[]*window{term(7, "logs - Term")},
+			map[int]hwnd{1: 7}, []int{0},
+		},
+		{
+			"ties go to the first saved window",
+			[]*window{term(0, "x - Term"), term(0, "x - Term")},
+			[]*window{term(7, "y - Term")},
+			map[int]hwnd{0: 7}, []int{1},
+		},
+		{
+			"ties go to the topmost live window",
+			[]*window{term(0, "x - Term")},
+			[]*window{term(7, "y - Term"), term(8, "z - Term")},
+			map[int]hwnd{0: 7}, nil,
+		},
+		{
+			"same handle breaks ties",
+			[]*window{term(8, "x - Term")},
+			[]*window{term(7, "y - Term"), term(8, "z - Term")},
+			map[int]hwnd{0: 8}, nil,
+		},
+		{
+			"below minimum score",
+			[]*window{{Name: "Calculator", Class: "Term"}},
+			[]*window{term(7, "y - Term")},
+			nil, []int{0},
+		},
+		{
+			"title regex",
+			[]*window{{Name: "old", Class: "Term", TitleRegex: `^\d+ - Term$`}},
+			[]*window{term(7, "y - Term"), term(8, "42 - Term")},
+			map[int]hwnd{0: 8}, nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matched, unmatched, err := matchWindows(tt.saved, tt.live)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(matched) != len(tt.want) {
+				t.Fatalf("%d windows matched, want %d", len(matched), len(tt.want))
+			}
+			for i, s := range tt.saved {
+				for _, m := range matched {
+					if m.Saved == s && m.Live.Hwnd != tt.want[i] {
+						t.Errorf("saved window %d matched to %d (%s), want %d", i, m.Live.Hwnd, m.Reason, tt.want[i])
+					}
+				}
+			}
+			if len(unmatched) != len(tt.unmatched) {
+				t.Fatalf("%d windows unmatched, want %d", len(unmatched), len(tt.unmatched))
+			}
+			for i, u := range tt.unmatched {
+				if unmatched[i] != tt.saved[u] {
+					t.Errorf("unmatched window %d is '%s', want saved window %d", i, unmatched[i].Name, u)
+				}
+			}
+		})
+	}
+}
+
+func TestMatchWindowsInvalidRegex(t *testing.T) {
+	if _, _, err := matchWindows([]*window{{Name: "a", TitleRegex: "("}}, nil); err == nil {
+		t.Errorf("invalid TitleRegex accepted")
+	}
+}
+
+func TestRestoreByIdentity(t *testing.T) {
+	st := &store{dir: t.TempDir()}
+	d := testDesktop(
+		&fakeWindow{Hwnd: 1, Name: "a.txt - Notepad", Class: "Notepad", Visible: true, R: rect{0, 0, 800, 600}},
+		&fakeWindow{Hwnd: 2, Name: "Calculator", Class: "Calc", Visible: true, R: rect{2000, 0, 2300, 500}},
+	)
+	if _, err := record(d, st, "work", nil); err != nil {
+		t.Fatal(err)
+	}
+	// after a reboot: new handles, the old ones reused by other windows
+	d = testDesktop(
+		&fakeWindow{Hwnd: 2, Name: "a.txt - Notepad", Class: "Notepad", Visible: true},
+		&fakeWindow{Hwnd: 1, Name: "Calculator", Class: "Calc", Visible: true},
+		&fakeWindow{Hwnd: 3, Name: "Paint", Class: "MSPaintApp", Visible: true},
+	)
+	if _, err := restore(d, st, "work", restoreOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for h, want := range map[hwnd]rect{2: {0, 0, 800, 600}, 1: {2000, 0, 2300, 500}, 3: {100, 100, 900, 700}} {
+		if _, fw, _ := d.find(h); fw.R != want {
+			t.Errorf("window %d '%s' at %v, want %v", h, fw.Name, fw.R, want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/binary"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/lxn/win"
+)
+
+type displayDevice struct {
+	Cb           uint32
+	DeviceName   [32]uint16
+	DeviceString [128]uint16
+	StateFlags   uint32
+	DeviceID     [128]uint16
+	DeviceKey    [128]uint16
+}
+
+// eddGetDeviceInterfaceName makes EnumDisplayDevices return the device
+// interface path of the monitor, like
+// \\?\DISPLAY#DEL4091#5&2b3c4d&0&UID4353#{e6f07b5f-ee97-4a90-b076-33f57bf4eaa7}
+const eddGetDeviceInterfaceName = 0x1
+
+// getMonitorID returns the device interface path of the (first) monitor
+// attached to a GDI display device, and the serial number of its EDID.
+func getMonitorID(device string) (string, string) {
+	var dd displayDevice
+	dd.Cb = uint32(unsafe.Sizeof(dd))
+	ret, _, _ := procEnumDisplayDevicesW.Call(uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(device))),
+		0, uintptr(unsafe.Pointer(&dd)), eddGetDeviceInterfaceName)
+	if ret == 0 {
+		return "", ""
+	}
+	id := syscall.UTF16ToString(dd.DeviceID[:])
+	return id, edidSerial(readEDID(id))
+}
+
+// readEDID reads the EDID the monitor of a device interface path
+// has in the registry, under its device instance.
+func readEDID(id string) []byte {
+	parts := strings.Split(id, "#")
+	if len(parts) < 3 {
+		return nil
+	}
+	path := `SYSTEM\CurrentControlSet\Enum\DISPLAY\` + parts[1] + `\` + parts[2] + `\Device Parameters`
+	var key win.HKEY
+	if win.RegOpenKeyEx(win.HKEY_LOCAL_MACHINE, syscall.StringToUTF16Ptr(path), 0, win.KEY_READ, &key) != win.ERROR_SUCCESS {
+		return nil
+	}
+	defer win.RegCloseKey(key)
+	buf := make([]byte, 256)
+	siz := uint32(len(buf))
+	if win.RegQueryValueEx(key, syscall.StringToUTF16Ptr("EDID"), nil, nil, &buf[0], &siz) != win.ERROR_SUCCESS {
+		return nil
+	}
+	return buf[:siz]
+}
+
+// edidSerial returns the serial number of an EDID: its display product
+// serial number descriptor, else its numeric ID serial number.
+// https://en.wikipedia.org/wiki/Extended_Display_Identification_Data
+func edidSerial(edid []byte) string {
+	if len(edid) < 128 {
+		return ""
+	}
+	for _, offset := range []int{54, 72, 90, 108} {
+		d := edid[offset : offset+18]
+		if d[0] == 0 && d[1] == 0 && d[2] == 0 && d[3] == 0xFF {
+			serial := string(d[5:])
+			if i := strings.IndexByte(serial, '\n'); i >= 0 {
+				serial = serial[:i]
+			}
+			if serial = strings.TrimSpace(serial); serial != "" {
+				return serial
+			}
+		}
+	}
+	if n := binary.LittleEndian.Uint32(edid[12:16]); n != 0 {
+		return strconv.FormatUint(uint64(n), 10)
+	}
+	return ""
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats, of the --output global flag.
+const (
+	outputText = "text"
+	outputJSON = "json"
+	outputYAML = "yaml"
+)
+
+// Exit codes.
+const (
+	exitOK      = 0
+	exitFailure = 1 // the command failed, or did none of what it was asked
+	exitUsage   = 2
+	exitPartial = 3 // the command did only part of what it was asked
+)
+
+// result is what a command reports: printed as text for people,
+// or marshaled as JSON or YAML for scripts.
+type result interface {
+	printText(w io.Writer)
+}
+
+// partialResult is a result of which some items may have failed.
+type partialResult interface {
+	failures() (failed, total int)
+}
+
+// writeResult writes res in the output format.
+func writeResult(w io.Writer, format string, res result) error {
+	switch format {
+	case outputJSON:
+		b, err := json.MarshalIndent(res, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+	case outputYAML:
+		b, err := marshalYAML(res)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	}
+	res.printText(w)
+	return nil
+}
+
+// marshalYAML marshals v as YAML, with the same fields as in JSON:
+// the JSON of v is parsed as a YAML document, in plain style.
+func marshalYAML(v interface{}) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	plainStyle(&doc)
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// plainStyle drops the quotes of the scalars, but for the strings
+// YAML 1.1 readers would take for booleans, like "yes" or "off".
+func plainStyle(n *yaml.Node) {
+	if n.Kind != yaml.ScalarNode || n.ShortTag() != "!!str" || !yaml11Bool.MatchString(n.Value) {
+		n.Style = 0
+	}
+	for _, c := range n.Content {
+		plainStyle(c)
+	}
+}
+
+var yaml11Bool = regexp.MustCompile(`^(y|Y|yes|Yes|YES|n|N|no|No|NO|true|True|TRUE|false|False|FALSE|on|On|ON|off|Off|OFF)$`)
+
+// exitCode tells how well the command did what it was asked.
+func exitCode(res result) int {
+	pr, ok := res.(partialResult)
+	if !ok {
+		return exitOK
+	}
+	switch failed, total := pr.failures(); {
+	case failed == 0:
+		return exitOK
+	case failed < total:
+		return exitPartial
+	}
+	return exitFailure
+}
+
+// windowInfo is a window, as reported by the commands.
+type windowInfo struct {
+	Name  string
+	Class string
+	Exe   string `json:",omitempty"`
+	R     rect
+}
+
+func newWindowInfo(w *window) windowInfo {
+	return windowInfo{Name: w.Name, Class: w.Class, Exe: w.Exe, R: w.R}
+}
+
+func windowInfos(l []*window) []windowInfo {
+	infos := make([]windowInfo, 0, len(l))
+	for _, w := range l {
+		infos = append(infos, newWindowInfo(w))
+	}
+	return infos
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestWriteResult(t *testing.T) {
+	res := &importResult{File: "zones.json", Format: "fancyzones", Layout: "yes", Windows: 3}
+	tests := []struct {
+		format, want string
+	}{
+		{outputText, "Winpos import: 3 window(s) of 'zones.json' (fancyzones) saved in layout 'yes'\n"},
+		{outputJSON, "{\n  \"File\": \"zones.json\",\n  \"Format\": \"fancyzones\",\n  \"Layout\": \"yes\",\n  \"Windows\": 3\n}\n"},
+		{outputYAML, "File: zones.json\nFormat: fancyzones\nLayout: \"yes\"\nWindows: 3\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeResult(&buf, tt.format, res); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("wrote\n%s\nwant\n%s", &buf, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteEmptyResults(t *testing.T) {
+	st := &store{dir: filepath.Join(t.TempDir(), "layouts")}
+	listed, err := list(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.save("empty", newLayout(testMonitors(), []*window{})); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := restore(testDesktop(), st, "empty", restoreOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// empty lists are written as such, not as null
+	for _, res := range []result{listed, restored} {
+		var buf bytes.Buffer
+		if err := writeResult(&buf, outputJSON, res); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(buf.String(), "null") {
+			t.Errorf("wrote\n%s\nwith a null list", &buf)
+		}
+	}
+}
+
+func TestMarshalYAML(t *testing.T) {
+	// the same fields as in JSON, omitted or named by the JSON tags
+	res := &editResult{Layout: "work", Action: editRemove, Windows: []windowInfo{{Name: "123", Class: "null", R: rect{0, 0, 10, 10}}}}
+	b, err := marshalYAML(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromYAML, fromJSON interface{}
+	if err := yaml.Unmarshal(b, &fromYAML); err != nil {
+		t.Fatal(err)
+	}
+	j, _ := json.Marshal(res)
+	if err := yaml.Unmarshal(j, &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	if y, _ := json.Marshal(fromYAML); !bytes.Equal(y, mustJSON(t, fromJSON)) {
+		t.Errorf("YAML\n%s\nreads as %s, want %s", b, y, mustJSON(t, fromJSON))
+	}
+}
+
+func mustJSON(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestExitCode(t *testing.T) {
+	restored := func(statuses ...string) *restoreResult {
+		r := &restoreResult{}
+		for _, s := range statuses {
+			r.Windows = append(r.Windows, windowOutcome{Status: s})
+		}
+		return r
+	}
+	tests := []struct {
+		name string
+		res  result
+		want int
+	}{
+		{"not partial", &importResult{}, exitOK},
+		{"nothing to restore", restored(), exitOK},
+		{"all moved", restored(outcomeMoved, outcomeMoved), exitOK},
+		{"dry run", restored(outcomePlanned), exitOK},
+		{"some failed", restored(outcomeMoved, outcomeAccessDenied, outcomeMissing), exitPartial},
+		{"all failed", restored(outcomeRejected, outcomeVanished), exitFailure},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.res); got != tt.want {
+			t.Errorf("%s: exit code %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/lxn/win"
+	"golang.org/x/sys/windows"
+)
+
+var (
+	libshell32                      *windows.LazyDLL
+	procSHGetPropertyStoreForWindow *windows.LazyProc
+
+	libole32             *windows.LazyDLL
+	procPropVariantClear *windows.LazyProc
+)
+
+func init() {
+	libshell32 = windows.NewLazySystemDLL("shell32.dll")
+	procSHGetPropertyStoreForWindow = libshell32.NewProc("SHGetPropertyStoreForWindow")
+	libole32 = windows.NewLazySystemDLL("ole32.dll")
+	procPropVariantClear = libole32.NewProc("PropVariantClear")
+
+	// Once the process has a multithreaded apartment, threads
+	// without COM initialization implicitly join it.
+	_ = windows.CoInitializeEx(0, windows.COINIT_MULTITHREADED)
+}
+
+// getProcess fills the process metadata of the window,
+// leaving empty what cannot be queried (elevated process, ...).
+func getProcess(hwnd win.HWND, w *window) {
+	w.AppID = getAppID(hwnd)
+	var pid uint32
+	if _, err := windows.GetWindowThreadProcessId(windows.HWND(hwnd), &pid); err != nil {
+		return
+	}
+	w.PID = pid
+	// Reading the working directory needs PROCESS_VM_READ, which more
+	// processes deny than PROCESS_QUERY_LIMITED_INFORMATION.
+	proc, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION|windows.PROCESS_VM_READ, false, pid)
+	if err != nil {
+		if proc, err = windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid); err != nil {
+			return
+		}
+	}
+	defer windows.CloseHandle(proc)
+	w.Exe = getExe(proc)
+	w.Cmdline = getCmdline(proc)
+	w.Dir = getDir(proc)
+}
+
+// getExe returns the executable path of the process.
+func getExe(proc windows.Handle) string {
+	var buf [windows.MAX_LONG_PATH]uint16
+	siz := uint32(len(buf))
+	if err := windows.QueryFullProcessImageName(proc, 0, &buf[0], &siz); err != nil {
+		return ""
+	}
+	return syscall.UTF16ToString(buf[:siz])
+}
+
+// getCmdline returns the command line of the process (Windows 8.1 and later).
+func getCmdline(proc windows.Handle) string {
+	var siz uint32
+	err := windows.NtQueryInformationProcess(proc, windows.ProcessCommandLineInformation, nil, 0, &siz)
+	if err != windows.STATUS_INFO_LENGTH_MISMATCH || siz == 0 {
+		return ""
+	}
+	// The UNICODE_STRING is followed by its characters,
+	// the buffer must be aligned for its pointer.
+	buf := make([]uintptr, (siz+uint32(unsafe.Sizeof(uintptr(0)))-1)/uint32(unsafe.Sizeof(uintptr(0))))
+	if err := windows.NtQueryInformationProcess(proc, windows.ProcessCommandLineInformation,
+		unsafe.Pointer(&buf[0]), siz, &siz); err != nil {
+		return ""
+	}
+	return (*windows.NTUnicodeString)(unsafe.Pointer(&buf[0])).String()
+}
+
+// getDir returns the current directory of the process, from the
+// RTL_USER_PROCESS_PARAMETERS of its PEB,
+// without the trailing backslash but the one of a drive root.
+// It is empty when the memory of the process cannot be read.
+func getDir(proc windows.Handle) string {
+	var pbi windows.PROCESS_BASIC_INFORMATION
+	if err := windows.NtQueryInformationProcess(proc, windows.ProcessBasicInformation,
+		unsafe.Pointer(&pbi), uint32(unsafe.Sizeof(pbi)), nil); err != nil || pbi.PebBaseAddress == nil {
+		return ""
+	}
+	// Only the start of both structures, which grow with Windows versions.
+	var peb windows.PEB
+	siz := unsafe.Offsetof(peb.ProcessParameters) + unsafe.Sizeof(peb.ProcessParameters)
+	if !readMemory(proc, uintptr(unsafe.Pointer(pbi.PebBaseAddress)), unsafe.Pointer(&peb), siz) || peb.ProcessParameters == nil {
+		return ""
+	}
+	var params windows.RTL_USER_PROCESS_PARAMETERS
+	siz = unsafe.Offsetof(params.CurrentDirectory) + unsafe.Sizeof(params.CurrentDirectory)
+	if !readMemory(proc, uintptr(unsafe.Pointer(peb.ProcessParameters)), unsafe.Pointer(&params), siz) {
+		return ""
+	}
+	path := params.CurrentDirectory.DosPath
+	if path.Length == 0 || path.Buffer == nil {
+		return ""
+	}
+	buf := make([]uint16, path.Length/2)
+	if !readMemory(proc, uintptr(unsafe.Pointer(path.Buffer)), unsafe.Pointer(&buf[0]), uintptr(path.Length)) {
+		return ""
+	}
+	dir := syscall.UTF16ToString(buf)
+	if len(dir) > 3 {
+		dir = strings.TrimSuffix(dir, `\`)
+	}
+	return dir
+}
+
+// readMemory reads size bytes of the process at addr into buf.
+func readMemory(proc windows.Handle, addr uintptr, buf unsafe.Pointer, size uintptr) bool {
+	var n uintptr
+	return windows.ReadProcessMemory(proc, addr, (*byte)(buf), size, &n) == nil && n == size
+}
+
+// IPropertyStore, and the PKEY_AppUserModel_ID property of a window.
+var (
+	iidIPropertyStore  = windows.GUID{Data1: 0x886d8eeb, Data2: 0x8cf2, Data3: 0x4446, Data4: [8]byte{0x8d, 0x02, 0xcd, 0xba, 0x1d, 0xbd, 0xcf, 0x99}}
+	pkeyAppUserModelID = propertyKey{
+		fmtid: windows.GUID{Data1: 0x9f4c2855, Data2: 0x9f79, Data3: 0x4b39, Data4: [8]byte{0xa8, 0xd0, 0xe1, 0xd4, 0x2d, 0xe1, 0xd5, 0xf3}},
+		pid:   5,
+	}
+)
+
+type propertyKey struct {
+	fmtid windows.GUID
+	pid   uint32
+}
+
+type propVariant struct {
+	vt       uint16
+	reserved [3]uint16
+	str      *uint16 // the union, as a VT_LPWSTR
+	_        uintptr
+}
+
+const vtLPWStr = 31
+
+type iPropertyStoreVtbl struct {
+	QueryInterface, AddRef, Release     uintptr
+	GetCount, GetAt, GetValue, SetValue uintptr
+	Commit                              uintptr
+}
+
+type iPropertyStore struct {
+	vtbl *iPropertyStoreVtbl
+}
+
+// getAppID returns the explicit AppUserModelID of the window, if any:
+// the one of packaged (Store) applications, and of applications setting it.
+func getAppID(hwnd win.HWND) string {
+	if procSHGetPropertyStoreForWindow.Find() != nil {
+		return ""
+	}
+	var ps *iPropertyStore
+	ret, _, _ := procSHGetPropertyStoreForWindow.Call(uintptr(hwnd),
+		uintptr(unsafe.Pointer(&iidIPropertyStore)), uintptr(unsafe.Pointer(&ps)))
+	if ret != 0 || ps == nil {
+		return ""
+	}
+	defer syscall.Syscall(ps.vtbl.Release, 1, uintptr(unsafe.Pointer(ps)), 0, 0)
+	var pv propVariant
+	ret, _, _ = syscall.Syscall(ps.vtbl.GetValue, 3, uintptr(unsafe.Pointer(ps)),
+		uintptr(unsafe.Pointer(&pkeyAppUserModelID)), uintptr(unsafe.Pointer(&pv)))
+	if ret != 0 {
+		return ""
+	}
+	defer procPropVariantClear.Call(uintptr(unsafe.Pointer(&pv)))
+	if pv.vt != vtLPWStr || pv.str == nil {
+		return ""
+	}
+	return windows.UTF16PtrToString(pv.str)
+}
+
+// Launch starts the application of a recorded window: packaged applications
+// through their AppUserModelID (see launchesApp), others with their recorded
+// command line, in their recorded Dir or the directory of their executable.
+func (user32) Launch(w *window) error {
+	var cmd *exec.Cmd
+	switch {
+	case launchesApp(w):
+		cmd = exec.Command("explorer.exe", `shell:AppsFolder\`+w.AppID)
+	case w.Exe != "":
+		cmd = exec.Command(w.Exe)
+		cmd.Dir = w.Dir
+		if cmd.Dir == "" {
+			cmd.Dir = filepath.Dir(w.Exe)
+		}
+		if w.Cmdline != "" {
+			cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: w.Cmdline}
+		}
+	default:
+		return fmt.Errorf("no executable recorded for '%s'", w.Name)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// Do not wait for the application: it outlives winpos.
+	return cmd.Process.Release()
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+)
+
+func cmdRecord(st *store, fs *flag.FlagSet, args []string) (result, error) {
+	selected := selectionFlags(fs)
+	name, err := layoutArg(fs, args, false)
+	if err != nil {
+		return nil, err
+	}
+	sel, err := selected()
+	if err != nil {
+		return nil, err
+	}
+	ws, err := newWindowSystem()
+	if err != nil {
+		return nil, err
+	}
+	return record(ws, st, name, sel)
+}
+
+// recordResult is what record reports.
+type recordResult struct {
+	Layout   string
+	Monitors int
+	Windows  []windowInfo
+	Warnings []string
+}
+
+func (r *recordResult) printText(w io.Writer) {
+	for _, warning := range r.Warnings {
+		fmt.Fprintf(w, "Winpos record: warning, %s\n", warning)
+	}
+	fmt.Fprintf(w, "Winpos record: %d window(s) on %d monitor(s) recorded in layout '%s'\n",
+		len(r.Windows), r.Monitors, r.Layout)
+}
+
+func record(ws WindowSystem, st *store, name string, sel *selection) (*recordResult, error) {
+	monitors, err := ws.Monitors()
+	if err != nil {
+		return nil, err
+	}
+	l, err := listWindows(ws)
+	if err != nil {
+		return nil, err
+	}
+	l = sel.filter(l, monitors)
+	if err := st.save(name, newLayout(monitors, l)); err != nil {
+		return nil, err
+	}
+	r := &recordResult{Layout: name, Monitors: len(monitors), Windows: windowInfos(l), Warnings: make([]string, 0)}
+	for _, w := range l {
+		if w.Placement == nil {
+			r.Warnings = append(r.Warnings, fmt.Sprintf("placement of '%s' unreadable, recorded from its rect only", w.Name))
+		}
+	}
+	return r, nil
+}
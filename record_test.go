@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestRecordProcessMetadata(t *testing.T) {
+	d, err := loadFakeDesktop("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Wins = []*fakeWindow{
+		{Hwnd: 1, Name: "a.txt - Notepad", Class: "Notepad", PID: 4242, Exe: `C:\Windows\notepad.exe`, Cmdline: `notepad.exe C:\a.txt`, Dir: `C:\notes`,
+			R: rect{100, 100, 900, 700}, Visible: true, Style: wsCaption},
+		{Hwnd: 2, Name: "Calculator", Class: "ApplicationFrameWindow", PID: 17, AppID: "Microsoft.WindowsCalculator_8wekyb3d8bbwe!App",
+			R: rect{0, 0, 400, 600}, Visible: true, Style: wsCaption},
+	}
+	st := &store{dir: t.TempDir()}
+	if _, err := record(d, st, "work", nil); err != nil {
+		t.Fatal(err)
+	}
+	var lay layout
+	if err := st.load("work", &lay); err != nil {
+		t.Fatal(err)
+	}
+	for i, fw := range d.Wins {
+		w := lay.Windows[i]
+		if w.PID != fw.PID || w.Exe != fw.Exe || w.Cmdline != fw.Cmdline || w.Dir != fw.Dir || w.AppID != fw.AppID {
+			t.Errorf("window %d recorded as %+v, want the metadata of %+v", i, w, fw)
+		}
+	}
+}
+
+func TestRecordSingleMonitor(t *testing.T) {
+	d, err := loadFakeDesktop("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Wins = []*fakeWindow{
+		{Hwnd: 1, Name: "a", Class: "A", R: rect{100, 100, 900, 700}, Visible: true, Style: wsCaption},
+		{Hwnd: 2, Name: "b", Class: "B", R: rect{0, 0, 1920, 1040}, Normal: rect{300, 200, 1100, 800}, Maximize: true, Visible: true, Style: wsCaption},
+	}
+	st := &store{dir: t.TempDir()}
+	r, err := record(d, st, "single", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Monitors != 1 || len(r.Windows) != 2 {
+		t.Fatalf("recorded %d window(s) on %d monitor(s), want 2 on 1", len(r.Windows), r.Monitors)
+	}
+	d.Wins[0].R = rect{500, 500, 700, 700}
+	d.Wins[1].Maximize = false
+	res, err := restore(d, st, "single", restoreOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Topology != topologySame || len(res.Warnings) != 0 {
+		t.Errorf("topology %s, warnings %q", res.Topology, res.Warnings)
+	}
+	if a := d.Wins[0]; a.Hwnd != 1 || a.R != (rect{100, 100, 900, 700}) {
+		t.Errorf("window %d at %s, want a back at (100,100), on top", a.Hwnd, a.R)
+	}
+	if b := d.Wins[1]; b.Hwnd != 2 || !b.Maximize || b.Normal != (rect{300, 200, 1100, 800}) {
+		t.Errorf("window %d at %s, normal %s, maximized %t: want b maximized back", b.Hwnd, b.R, b.Normal, b.Maximize)
+	}
+}
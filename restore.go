@@ -0,0 +1,390 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+func cmdRestore(st *store, fs *flag.FlagSet, args []string) (result, error) {
+	auto := fs.Bool("auto", false, "restore the layout recorded with the monitors now connected")
+	at := fs.String("at", "", "restore a snapshot of the layout: its rank (0 for the latest) or a date and time")
+	dryRun := fs.Bool("dry-run", false, "print what would be restored, without moving any window")
+	launch := fs.Bool("launch-missing", false, "start the applications of the recorded windows not running")
+	mapping := fs.String("mapping", "", "comma-separated monitor mapping strategies, overriding Restore.Mapping")
+	selected := selectionFlags(fs)
+	launchTimeout := fs.Duration("launch-timeout", time.Duration(conf.Restore.LaunchTimeout), "time to wait for the windows of the started applications")
+	pos, err := parseArgs(fs, args)
+	if err != nil {
+		return nil, err
+	}
+	if *auto && len(pos) != 0 {
+		return nil, errUsage
+	}
+	name, err := layoutName(pos, false)
+	if err != nil {
+		return nil, err
+	}
+	if *mapping != "" {
+		conf.Restore.Mapping = strings.Split(*mapping, ",")
+		if err := conf.validate(); err != nil {
+			return nil, err
+		}
+	}
+	sel, err := selected()
+	if err != nil {
+		return nil, err
+	}
+	ws, err := newWindowSystem()
+	if err != nil {
+		return nil, err
+	}
+	if *auto {
+		monitors, err := ws.Monitors()
+		if err != nil {
+			return nil, err
+		}
+		if name, err = selectLayout(st, monitors); err != nil {
+			return nil, err
+		}
+	}
+	r, err := restore(ws, st, name, restoreOptions{
+		at:            *at,
+		dryRun:        *dryRun,
+		launchMissing: *launch,
+		launchTimeout: *launchTimeout,
+		sel:           sel,
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.Auto = *auto
+	return r, nil
+}
+
+type restoreOptions struct {
+	at            string // snapshot to restore, see store.snapshotAt
+	dryRun        bool
+	launchMissing bool
+	launchTimeout time.Duration
+	sel           *selection // recorded windows to restore, all if nil
+}
+
+// restoreResult is what restore reports.
+type restoreResult struct {
+	Layout   string
+	Auto     bool `json:",omitempty"` // layout selected for the connected monitors
+	Topology topology
+	Warnings []string
+	DryRun   bool            `json:",omitempty"`
+	Windows  []windowOutcome // in layout order, then the vanished and the missing ones
+}
+
+// Window outcomes.
+const (
+	outcomeMoved        = "moved"
+	outcomePlanned      = "planned"       // would be moved, on a dry run
+	outcomeAccessDenied = "access denied" // elevated window
+	outcomeVanished     = "vanished"      // closed while being restored
+	outcomeRejected     = "rejected"      // placement failed, or not where asked
+	outcomeMissing      = "missing"       // no live window
+)
+
+// outcomeOf tells the outcome of a move which ended with err.
+func outcomeOf(err error) string {
+	switch {
+	case err == nil:
+		return outcomeMoved
+	case errors.Is(err, errAccessDenied):
+		return outcomeAccessDenied
+	case errors.Is(err, errVanished):
+		return outcomeVanished
+	}
+	return outcomeRejected
+}
+
+// windowOutcome is what restore did to a recorded window.
+type windowOutcome struct {
+	Name, Class string
+	Status      string
+	From        *placement `json:",omitempty"` // placement of the live window
+	To          *placement `json:",omitempty"` // placement it was restored to
+	Match       string     `json:",omitempty"` // why the live window matched
+	Score       int        `json:",omitempty"`
+	Launch      string     `json:",omitempty"` // command started, or to start, for the window
+	Attempts    int        `json:",omitempty"` // placements tried, retries included
+	Error       string     `json:",omitempty"`
+}
+
+func (r *restoreResult) failures() (int, int) {
+	failed := 0
+	for _, o := range r.Windows {
+		if o.Status != outcomeMoved && o.Status != outcomePlanned {
+			failed++
+		}
+	}
+	return failed, len(r.Windows)
+}
+
+// summary counts the windows per outcome, in the order of their first
+// occurrence, like "3 moved, 1 access denied, 2 missing".
+func (r *restoreResult) summary() string {
+	counts := make(map[string]int)
+	var order []string
+	for _, o := range r.Windows {
+		if counts[o.Status] == 0 {
+			order = append(order, o.Status)
+		}
+		counts[o.Status]++
+	}
+	l := make([]string, 0, len(order))
+	for _, status := range order {
+		l = append(l, fmt.Sprintf("%d %s", counts[status], status))
+	}
+	return strings.Join(l, ", ")
+}
+
+func (r *restoreResult) printText(w io.Writer) {
+	if r.Auto {
+		fmt.Fprintf(w, "Winpos restore: layout '%s' selected for the connected monitors\n", r.Layout)
+	}
+	for _, warning := range r.Warnings {
+		fmt.Fprintf(w, "Winpos restore: warning, %s\n", warning)
+	}
+	if r.DryRun {
+		matched := 0
+		for _, o := range r.Windows {
+			if o.Status != outcomeMissing {
+				matched++
+			}
+		}
+		fmt.Fprintf(w, "Winpos restore: dry run, %d/%d window(s) of layout '%s' matched\n", matched, len(r.Windows), r.Layout)
+	} else {
+		for _, o := range r.Windows {
+			if o.Launch != "" {
+				fmt.Fprintf(w, "  launched %s for '%s'\n", o.Launch, o.Name)
+			}
+		}
+	}
+	for _, o := range r.Windows {
+		switch {
+		case o.Status == outcomePlanned:
+			fmt.Fprintf(w, "  %s\n      %s -> %s  [%s, score %d]\n", o.Name, o.From, o.To, o.Match, o.Score)
+		case o.Status == outcomeMissing && r.DryRun && o.Launch != "":
+			fmt.Fprintf(w, "  would launch %s for '%s'\n", o.Launch, o.Name)
+		case o.Status == outcomeMissing:
+			fmt.Fprintf(w, "  no live window for '%s' (%s)\n", o.Name, o.Class)
+		case o.Status == outcomeVanished && o.Attempts == 0:
+			fmt.Fprintf(w, "  %s: '%s', before any attempt: %s\n", o.Status, o.Name, o.Error)
+		case o.Status != outcomeMoved:
+			fmt.Fprintf(w, "  %s: '%s', after %d attempt(s): %s\n", o.Status, o.Name, o.Attempts, o.Error)
+		}
+	}
+	if !r.DryRun {
+		failed, total := r.failures()
+		fmt.Fprintf(w, "Winpos restore: %d/%d window(s) of layout '%s' restored", total-failed, total, r.Layout)
+		if total > 0 {
+			fmt.Fprintf(w, ": %s", r.summary())
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func restore(ws WindowSystem, st *store, name string, opts restoreOptions) (*restoreResult, error) {
+	// load it back
+	var lay layout
+	if err := st.loadAt(name, opts.at, &lay); err != nil {
+		return nil, err
+	}
+	lay.Windows = opts.sel.filter(lay.Windows, lay.Monitors)
+	p, err := planRestore(ws, &lay)
+	if err != nil {
+		return nil, err
+	}
+	r := &restoreResult{Layout: name, Topology: p.Topology, Warnings: make([]string, 0), Windows: make([]windowOutcome, 0), DryRun: opts.dryRun}
+	switch p.Topology {
+	case topologyMissing:
+		r.Warnings = append(r.Warnings, fmt.Sprintf("%s since layout '%s' was recorded, the windows of %d monitor(s) are collapsed onto the others: %s", p.Topology, name, p.collapsed, p.TopologyDiff))
+	case topologyChanged:
+		r.Warnings = append(r.Warnings, fmt.Sprintf("%s since layout '%s' was recorded, windows are rescaled: %s", p.Topology, name, p.TopologyDiff))
+	case topologyUnknown:
+		r.Warnings = append(r.Warnings, fmt.Sprintf("layout '%s' has no monitor recorded", name))
+	}
+	if opts.dryRun {
+		for _, mv := range p.Moves {
+			r.Windows = append(r.Windows, mv.outcome(outcomePlanned))
+		}
+		r.Windows = append(r.Windows, p.Vanished...)
+		for _, w := range p.Unmatched {
+			o := windowOutcome{Name: w.Name, Class: w.Class, Status: outcomeMissing}
+			if opts.launchMissing && launchable(w) {
+				o.Launch = launchCommand(w)
+			}
+			r.Windows = append(r.Windows, o)
+		}
+		return r, nil
+	}
+	if opts.launchMissing {
+		if err := p.launchMissing(ws, opts.launchTimeout); err != nil {
+			return nil, err
+		}
+	}
+	placed, warnings := p.apply(ws)
+	r.Warnings = append(r.Warnings, warnings...)
+	for i, mv := range p.Moves {
+		o := mv.outcome(outcomeOf(placed[i].err))
+		o.Attempts = placed[i].attempts
+		if placed[i].err != nil {
+			o.Error = placed[i].err.Error()
+		}
+		o.Launch = p.launched[mv.Saved]
+		r.Windows = append(r.Windows, o)
+	}
+	r.Windows = append(r.Windows, p.Vanished...)
+	for _, w := range p.Unmatched {
+		r.Windows = append(r.Windows, windowOutcome{Name: w.Name, Class: w.Class, Status: outcomeMissing, Launch: p.launched[w]})
+	}
+	return r, nil
+}
+
+// move is what restore does to one window.
+type move struct {
+	match
+	From placement // the current placement of the live window
+	To   placement // its placement once restored
+}
+
+func (mv move) outcome(status string) windowOutcome {
+	return windowOutcome{Name: mv.Live.Name, Class: mv.Live.Class, Status: status,
+		From: &mv.From, To: &mv.To, Match: mv.Reason, Score: mv.Score}
+}
+
+// restorePlan is what restoring a layout does to the current desktop.
+type restorePlan struct {
+	Topology     topology
+	TopologyDiff string
+	Moves        []move          // in layout order
+	Vanished     []windowOutcome // matched windows closed before their placement was read
+	Unmatched    []*window       // recorded windows without a live counterpart
+	Unrecorded   []*window       // live windows not in the layout
+
+	*monitorMap
+	launched map[*window]string // command started for recorded windows, see launchMissing
+}
+
+// planRestore matches the windows of lay to the live ones, and computes
+// where each of them goes on the current monitors.
+func planRestore(ws WindowSystem, lay *layout) (*restorePlan, error) {
+	monitors, err := ws.Monitors()
+	if err != nil {
+		return nil, err
+	}
+	p := &restorePlan{monitorMap: newMonitorMap(lay, monitors)}
+	p.Topology, p.TopologyDiff = compareTopology(lay.Monitors, monitors)
+	live, err := listWindows(ws)
+	if err != nil {
+		return nil, err
+	}
+	matched, unmatched, err := matchWindows(lay.Windows, live)
+	if err != nil {
+		return nil, err
+	}
+	p.Unmatched = unmatched
+	isMatched := make(map[*window]bool)
+	for _, m := range matched {
+		isMatched[m.Live] = true
+		p.addMove(ws, m)
+	}
+	for _, l := range live {
+		if !isMatched[l] {
+			p.Unrecorded = append(p.Unrecorded, l)
+		}
+	}
+	return p, nil
+}
+
+// addMove adds the move of a matched window, or records it as vanished
+// when its placement cannot be read.
+func (p *restorePlan) addMove(ws WindowSystem, m match) {
+	from, err := ws.Placement(m.Live.Hwnd)
+	if err != nil {
+		p.Vanished = append(p.Vanished, windowOutcome{Name: m.Live.Name, Class: m.Live.Class, Status: outcomeVanished,
+			Match: m.Reason, Score: m.Score, Error: err.Error()})
+		return
+	}
+	p.Moves = append(p.Moves, move{match: m, From: from, To: p.monitorMap.targetPlacement(m.Saved.placement())})
+}
+
+// placed is how placing a window went.
+type placed struct {
+	attempts int
+	err      error
+}
+
+// apply places the windows, then restacks them as recorded,
+// and activates the one which was the foreground window.
+// It returns how placing each move went, and warnings about the rest.
+func (p *restorePlan) apply(ws WindowSystem) ([]placed, []string) {
+	res := make([]placed, len(p.Moves))
+	var warnings []string
+	byZ := make([]int, len(p.Moves))
+	for i := range byZ {
+		byZ[i] = i
+	}
+	sort.SliceStable(byZ, func(i, j int) bool { return p.Moves[byZ[i]].Saved.Z < p.Moves[byZ[j]].Saved.Z })
+	order := make([]hwnd, 0, len(byZ))
+	for _, i := range byZ {
+		mv := p.Moves[i]
+		debugf("Winpos restore: '%s' -> %s [%s, score %d]", mv.Live.Name, mv.To, mv.Reason, mv.Score)
+		res[i] = place(ws, mv)
+		if res[i].err == nil {
+			order = append(order, mv.Live.Hwnd)
+		}
+	}
+	if err := ws.Restack(order); err != nil {
+		warnings = append(warnings, fmt.Sprintf("windows not restacked: %v", err))
+	}
+	for i, mv := range p.Moves {
+		if mv.Saved.Foreground && res[i].err == nil {
+			if err := ws.Focus(mv.Live.Hwnd); err != nil {
+				warnings = append(warnings, fmt.Sprintf("'%s' not activated: %v", mv.Live.Name, err))
+			}
+		}
+	}
+	return res, warnings
+}
+
+// place moves a window, and checks it got where asked. It retries up to
+// Restore.Retries times when the placement is rejected, which may be
+// transient, waiting Restore.RetryDelay, then twice longer each time.
+func place(ws WindowSystem, mv move) placed {
+	delay := time.Duration(conf.Restore.RetryDelay)
+	for attempts := 1; ; attempts++ {
+		err := ws.SetPlacement(mv.Live.Hwnd, mv.To)
+		if err == nil {
+			err = verify(ws, mv)
+		}
+		if err == nil || !errors.Is(err, errRejected) || attempts > conf.Restore.Retries {
+			return placed{attempts: attempts, err: err}
+		}
+		debugf("Winpos restore: '%s': %v, retrying in %s", mv.Live.Name, err, delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// verify checks that the window of mv actually got to its target.
+func verify(ws WindowSystem, mv move) error {
+	got, err := ws.Placement(mv.Live.Hwnd)
+	if err != nil {
+		return err
+	}
+	if !mv.To.reached(got) {
+		return fmt.Errorf("placed at %s instead of %s: %w", got, mv.To, errRejected)
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// focusCounter is a fake desktop recording the windows it activates.
+type focusCounter struct {
+	*fakeDesktop
+	focused []hwnd
+}
+
+func (d *focusCounter) Focus(h hwnd) error {
+	d.focused = append(d.focused, h)
+	return d.fakeDesktop.Focus(h)
+}
+
+// restoreDesktop is a fake desktop of three terminals, stacked 3, 2, 1,
+// and a layout recording them stacked 1, 2, 3, the second one active
+// and maximized on the second monitor.
+func restoreDesktop() (*focusCounter, *layout) {
+	d := testDesktop(
+		&fakeWindow{Hwnd: 3, Name: "c - Term", Class: "Term", Visible: true},
+		&fakeWindow{Hwnd: 2, Name: "b - Term", Class: "Term", Visible: true},
+		&fakeWindow{Hwnd: 1, Name: "a - Term", Class: "Term", Visible: true},
+	)
+	d.Active = 3
+	saved := []*window{
+		{Name: "a - Term", Class: "Term", R: rect{0, 0, 800, 600}},
+		{Name: "b - Term", Class: "Term", R: rect{2000, 100, 2800, 700}, Maximize: true},
+		{Name: "c - Term", Class: "Term", R: rect{1000, 400, 1800, 1000}},
+	}
+	for i, w := range saved {
+		w.Z = i
+	}
+	saved[1].Foreground = true
+	return &focusCounter{fakeDesktop: d}, newLayout(testMonitors(), saved)
+}
+
+func TestRestorePlanApply(t *testing.T) {
+	d, lay := restoreDesktop()
+	d.Wins = append(d.Wins, &fakeWindow{Hwnd: 4, Name: "Calculator", Class: "Calc", Visible: true, Style: wsCaption, R: rect{10, 10, 300, 500}})
+	lay.Windows = append(lay.Windows, &window{Name: "Paint", Class: "MSPaintApp", R: rect{0, 0, 640, 480}})
+	p, err := planRestore(d, lay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Topology != topologySame || len(p.Moves) != 3 {
+		t.Fatalf("plan %+v, want the three terminals moved on the same monitors", p)
+	}
+	if len(p.Unmatched) != 1 || p.Unmatched[0].Name != "Paint" || len(p.Unrecorded) != 1 || p.Unrecorded[0].Name != "Calculator" {
+		t.Errorf("unmatched %v, unrecorded %v: want Paint, and Calculator", p.Unmatched, p.Unrecorded)
+	}
+	for _, mv := range p.Moves {
+		if mv.From.R != (rect{100, 100, 900, 700}) || !mv.To.same(mv.Saved.placement()) {
+			t.Errorf("'%s' moved from %s to %s, want to %s", mv.Live.Name, mv.From, mv.To, mv.Saved.placement())
+		}
+	}
+	p.apply(d)
+	for h, want := range map[hwnd]rect{1: {0, 0, 800, 600}, 2: {1920, 0, 3840, 1040}, 3: {1000, 400, 1800, 1000}} {
+		if _, fw, _ := d.find(h); fw.R != want || fw.Maximize != (h == 2) {
+			t.Errorf("window %d at %s, maximized %t: want at %s", h, fw.R, fw.Maximize, want)
+		}
+	}
+	// b activated, then a and c stacked below it as recorded,
+	// over the unrecorded window
+	for i, want := range []hwnd{2, 1, 3, 4} {
+		if d.Wins[i].Hwnd != want {
+			t.Errorf("window %d at z %d, want %d", d.Wins[i].Hwnd, i, want)
+		}
+	}
+	if d.Active != 2 || len(d.focused) != 1 {
+		t.Errorf("activated %v, want 2 once", d.focused)
+	}
+}
+
+func TestRestorePlanApplyFailures(t *testing.T) {
+	setConfig(t, func(c *config) { c.Restore.RetryDelay = 0 })
+	d, lay := restoreDesktop()
+	d.Wins[1].Elevated = true // b, the foreground window
+	d.Wins[2].Fixed = true    // a
+	p, err := planRestore(d, lay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, _ := p.apply(d)
+	if !errors.Is(res[0].err, errRejected) || res[0].attempts != conf.Restore.Retries+1 {
+		t.Errorf("fixed window: %v after %d attempt(s), want rejected after %d", res[0].err, res[0].attempts, conf.Restore.Retries+1)
+	}
+	if !errors.Is(res[1].err, errAccessDenied) || res[1].attempts != 1 {
+		t.Errorf("elevated window: %v after %d attempt(s), want denied at once", res[1].err, res[1].attempts)
+	}
+	if res[2].err != nil {
+		t.Errorf("window c: %v", res[2].err)
+	}
+	// only c placed, and restacked; the foreground window not placed is not activated
+	if len(d.focused) != 0 || d.Active != 3 || d.Wins[0].Hwnd != 3 {
+		t.Errorf("activated %v, top window %d: want none activated, 3 on top", d.focused, d.Wins[0].Hwnd)
+	}
+}
+
+func TestRestoreZOrder(t *testing.T) {
+	d, lay := restoreDesktop()
+	d.Wins = append([]*fakeWindow{{Hwnd: 9, Name: "Calculator", Class: "Calc", Visible: true, Style: wsCaption, R: rect{10, 10, 300, 500}}}, d.Wins...)
+	lay.Windows[1].Foreground = false
+	// recorded out of z-order
+	lay.Windows[0].Z, lay.Windows[2].Z = 2, 0
+	p, err := planRestore(d, lay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.apply(d)
+	// the recorded windows over the unrecorded one, none activated
+	for i, want := range []hwnd{3, 2, 1, 9} {
+		if d.Wins[i].Hwnd != want {
+			t.Errorf("window %d at z %d, want %d", d.Wins[i].Hwnd, i, want)
+		}
+	}
+	if len(d.focused) != 0 {
+		t.Errorf("activated %v, want none", d.focused)
+	}
+}
+
+func TestRestoreDryRun(t *testing.T) {
+	d, lay := restoreDesktop()
+	st := &store{dir: t.TempDir()}
+	if err := st.save("work", lay); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restore(d, st, "work", restoreOptions{dryRun: true}); err != nil {
+		t.Fatal(err)
+	}
+	for _, fw := range d.Wins {
+		if fw.R != (rect{100, 100, 900, 700}) || fw.Maximize {
+			t.Errorf("dry run moved '%s' to %s", fw.Name, fw.R)
+		}
+	}
+	if d.Wins[0].Hwnd != 3 || len(d.focused) != 0 {
+		t.Errorf("dry run restacked or activated the windows")
+	}
+}
+
+func TestRestoreStates(t *testing.T) {
+	d := testDesktop(
+		&fakeWindow{Hwnd: 1, Name: "min", Visible: true},
+		&fakeWindow{Hwnd: 2, Name: "snap", Visible: true},
+	)
+	normal := rect{100, 100, 900, 700}
+	minimized := &window{Name: "min", R: normal, Placement: &placement{State: stateMinimized, Normal: normal, R: normal}}
+	snapped := &window{Name: "snap", R: rect{-8, 0, 968, 1048}, Placement: &placement{State: stateNormal, Normal: normal, R: rect{-8, 0, 968, 1048}}}
+	p, err := planRestore(d, newLayout(testMonitors(), []*window{minimized, snapped}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.apply(d)
+	if _, fw, _ := d.find(1); !fw.Minimize || fw.Normal != normal {
+		t.Errorf("window min %+v, want minimized from %s", fw, normal)
+	}
+	if _, fw, _ := d.find(2); fw.R != snapped.Placement.R || fw.Normal != normal {
+		t.Errorf("window snap at %s, normal %s: want snapped back", fw.R, fw.Normal)
+	}
+}
+
+func TestOutcomeOf(t *testing.T) {
+	tests := map[error]string{
+		nil: outcomeMoved,
+		fmt.Errorf("window 1 is elevated: %w", errAccessDenied): outcomeAccessDenied,
+		fmt.Errorf("no window 1: %w", errVanished):              outcomeVanished,
+		fmt.Errorf("placed elsewhere: %w", errRejected):         outcomeRejected,
+		errors.New("unexpected"):                                outcomeRejected,
+	}
+	for err, want := range tests {
+		if got := outcomeOf(err); got != want {
+			t.Errorf("outcomeOf(%v) = %s, want %s", err, got, want)
+		}
+	}
+}
+
+func TestRestoreOutcomes(t *testing.T) {
+	setConfig(t, func(c *config) { c.Restore.RetryDelay = 0 })
+	d, lay := restoreDesktop()
+	d.Wins[1].Elevated = true
+	lay.Windows = append(lay.Windows, &window{Name: "Paint", Class: "MSPaintApp", R: rect{0, 0, 640, 480}})
+	st := &store{dir: t.TempDir()}
+	if err := st.save("work", lay); err != nil {
+		t.Fatal(err)
+	}
+	r, err := restore(d, st, "work", restoreOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.summary(), "2 moved, 1 access denied, 1 missing"; got != want {
+		t.Errorf("summary %s, want %s", got, want)
+	}
+	if failed, total := r.failures(); failed != 2 || total != 4 || exitCode(r) != exitPartial {
+		t.Errorf("%d/%d failed, exit code %d", failed, total, exitCode(r))
+	}
+	var out bytes.Buffer
+	r.printText(&out)
+	for _, want := range []string{
+		"  access denied: 'b - Term', after 1 attempt(s): window 2 is elevated: access denied\n",
+		"  no live window for 'Paint' (MSPaintApp)\n",
+		"Winpos restore: 2/4 window(s) of layout 'work' restored: 2 moved, 1 access denied, 1 missing\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("restore printed\n%s\nwithout %q", &out, want)
+		}
+	}
+}
+
+// closingDesktop is a fake desktop on which a window closes
+// between its listing and the read of its placement.
+type closingDesktop struct {
+	*fakeDesktop
+	closing hwnd
+}
+
+func (d *closingDesktop) Placement(h hwnd) (placement, error) {
+	if h == d.closing {
+		return placement{}, fmt.Errorf("no window %d: %w", h, errVanished)
+	}
+	return d.fakeDesktop.Placement(h)
+}
+
+func TestRestoreVanished(t *testing.T) {
+	setConfig(t, func(c *config) { c.Restore.RetryDelay = 0 })
+	fc, lay := restoreDesktop()
+	d := &closingDesktop{fakeDesktop: fc.fakeDesktop, closing: 2}
+	st := &store{dir: t.TempDir()}
+	if err := st.save("work", lay); err != nil {
+		t.Fatal(err)
+	}
+	for _, dryRun := range []bool{true, false} {
+		r, err := restore(d, st, "work", restoreOptions{dryRun: dryRun})
+		if err != nil {
+			t.Fatalf("dry run %t: %v", dryRun, err)
+		}
+		if len(r.Windows) != 3 || r.Windows[2].Name != "b - Term" || r.Windows[2].Status != outcomeVanished {
+			t.Errorf("dry run %t: outcomes %+v, want b vanished last", dryRun, r.Windows)
+		}
+	}
+	r, _ := restore(d, st, "work", restoreOptions{})
+	if got, want := r.summary(), "2 moved, 1 vanished"; got != want || exitCode(r) != exitPartial {
+		t.Errorf("summary %s, exit code %d: want %s, partial", got, exitCode(r), want)
+	}
+	var out bytes.Buffer
+	r.printText(&out)
+	if want := "  vanished: 'b - Term', before any attempt: no window 2: window vanished\n"; !strings.Contains(out.String(), want) {
+		t.Errorf("restore printed\n%s\nwithout %q", &out, want)
+	}
+	if _, a, _ := d.find(1); a.R != (rect{0, 0, 800, 600}) {
+		t.Errorf("a at %s, want restored at (0,0)", a.R)
+	}
+}
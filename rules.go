@@ -0,0 +1,264 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const rulesFile = "rules.json"
+
+// rule selects the windows meeting all its criteria.
+// Exe, Class and Title are case-insensitive globs ('*' and '?');
+// Exe matches the full executable path or its base name.
+type rule struct {
+	Action     string // "include" or "exclude"
+	Exe        string `json:",omitempty"`
+	Class      string `json:",omitempty"`
+	Title      string `json:",omitempty"`
+	TitleRegex string `json:",omitempty"`
+	Monitor    int    `json:",omitempty"` // rank of the monitor, from 1
+
+	exe, class, title, titleRegex *regexp.Regexp
+}
+
+// selection is the include and exclude rules applied to the windows,
+// at record and restore time.
+// A window is selected when there is no include rule or it meets one of them,
+// and it meets none of the exclude rules.
+type selection struct {
+	include, exclude []*rule
+}
+
+// loadRules reads the rules file at path; a missing file means no rule.
+func loadRules(path string) (*selection, error) {
+	sel := &selection{}
+	var rules []*rule
+	if err := Load(path, &rules); err != nil {
+		if os.IsNotExist(err) {
+			return sel, nil
+		}
+		return nil, fmt.Errorf("invalid rules file '%s': %v", path, err)
+	}
+	for i, r := range rules {
+		if err := sel.add(r); err != nil {
+			return nil, fmt.Errorf("invalid rule %d in '%s': %v", i+1, path, err)
+		}
+	}
+	return sel, nil
+}
+
+func (sel *selection) add(r *rule) error {
+	if err := r.compile(); err != nil {
+		return err
+	}
+	switch r.Action {
+	case "include":
+		sel.include = append(sel.include, r)
+	case "exclude":
+		sel.exclude = append(sel.exclude, r)
+	default:
+		return fmt.Errorf("action '%s' is neither 'include' nor 'exclude'", r.Action)
+	}
+	return nil
+}
+
+// override applies the --only and --exclude rules of the command line:
+// the --only ones replace the include rules, the --exclude ones are added.
+func (sel *selection) override(only, exclude []*rule) error {
+	if len(only) > 0 {
+		sel.include = nil
+	}
+	for _, r := range append(only, exclude...) {
+		if err := sel.add(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// selects tells whether the window, on the given monitors, is selected.
+func (sel *selection) selects(w *window, monitors []*monitor) bool {
+	if sel == nil {
+		return true
+	}
+	included := len(sel.include) == 0
+	for _, r := range sel.include {
+		if r.matches(w, monitors) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+	for _, r := range sel.exclude {
+		if r.matches(w, monitors) {
+			return false
+		}
+	}
+	return true
+}
+
+// filter returns the selected windows.
+func (sel *selection) filter(l []*window, monitors []*monitor) []*window {
+	r := make([]*window, 0, len(l))
+	for _, w := range l {
+		if sel.selects(w, monitors) {
+			r = append(r, w)
+		}
+	}
+	return r
+}
+
+func (r *rule) compile() error {
+	var err error
+	if r.exe, err = glob(r.Exe); err != nil {
+		return err
+	}
+	if r.class, err = glob(r.Class); err != nil {
+		return err
+	}
+	if r.title, err = glob(r.Title); err != nil {
+		return err
+	}
+	if r.TitleRegex != "" {
+		if r.titleRegex, err = regexp.Compile(r.TitleRegex); err != nil {
+			return err
+		}
+	}
+	if r.exe == nil && r.class == nil && r.title == nil && r.titleRegex == nil && r.Monitor == 0 {
+		return fmt.Errorf("rule without any criterion")
+	}
+	return nil
+}
+
+func (r *rule) matches(w *window, monitors []*monitor) bool {
+	if r.exe != nil && !r.exe.MatchString(w.Exe) && !r.exe.MatchString(baseName(w.Exe)) {
+		return false
+	}
+	if r.class != nil && !r.class.MatchString(w.Class) {
+		return false
+	}
+	if r.title != nil && !r.title.MatchString(w.Name) {
+		return false
+	}
+	if r.titleRegex != nil && !r.titleRegex.MatchString(w.Name) {
+		return false
+	}
+	if r.Monitor != 0 && monitorIndex(w.placement().Normal, monitors)+1 != r.Monitor {
+		return false
+	}
+	return true
+}
+
+// glob compiles a case-insensitive glob, nil for an empty one.
+func glob(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	var b strings.Builder
+	b.WriteString("(?i)^")
+	for _, c := range pattern {
+		switch c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// baseName returns the last element of a Windows or slash-separated path.
+func baseName(path string) string {
+	return path[strings.LastIndexAny(path, `\/`)+1:]
+}
+
+// ruleFlag parses the --only and --exclude rules of the command line:
+// comma-separated criteria, like "exe:chrome.exe,title:*Jira*".
+type ruleFlag struct {
+	action string
+	rules  []*rule
+}
+
+func (f *ruleFlag) String() string {
+	return ""
+}
+
+func (f *ruleFlag) Set(spec string) error {
+	r, err := parseRule(f.action, spec)
+	if err != nil {
+		return err
+	}
+	f.rules = append(f.rules, r)
+	return nil
+}
+
+// parseRule parses comma-separated criteria, like "exe:chrome.exe,title:*Jira*".
+func parseRule(action, spec string) (*rule, error) {
+	r := &rule{Action: action}
+	for _, criterion := range strings.Split(spec, ",") {
+		i := strings.Index(criterion, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("criterion '%s' is not <exe|class|title|regex|monitor>:<value>", criterion)
+		}
+		value := criterion[i+1:]
+		switch criterion[:i] {
+		case "exe":
+			r.Exe = value
+		case "class":
+			r.Class = value
+		case "title":
+			r.Title = value
+		case "regex":
+			r.TitleRegex = value
+		case "monitor":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("monitor '%s' is not a rank from 1", value)
+			}
+			r.Monitor = n
+		default:
+			return nil, fmt.Errorf("unknown criterion '%s': use exe, class, title, regex or monitor", criterion[:i])
+		}
+	}
+	if err := r.compile(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// selectionFlags declares --only and --exclude, and returns the function
+// giving, once the flags are parsed, the rules file overridden by them.
+func selectionFlags(fs *flag.FlagSet) func() (*selection, error) {
+	only := &ruleFlag{action: "include"}
+	exclude := &ruleFlag{action: "exclude"}
+	fs.Var(only, "only", "only the windows meeting `criteria`, like exe:chrome.exe,title:*Jira* (repeatable)")
+	fs.Var(exclude, "exclude", "not the windows meeting `criteria` (repeatable)")
+	return func() (*selection, error) {
+		path := conf.Filters.Rules
+		if path == "" {
+			dir, err := configDir()
+			if err != nil {
+				return nil, err
+			}
+			path = filepath.Join(dir, rulesFile)
+		}
+		sel, err := loadRules(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := sel.override(only.rules, exclude.rules); err != nil {
+			return nil, err
+		}
+		return sel, nil
+	}
+}
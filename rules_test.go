@@ -0,0 +1,193 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testRule parses a rule as given to --only or --exclude.
+func testRule(t *testing.T, action, spec string) *rule {
+	t.Helper()
+	f := &ruleFlag{action: action}
+	if err := f.Set(spec); err != nil {
+		t.Fatal(err)
+	}
+	return f.rules[0]
+}
+
+func TestGlob(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"chrome.exe", "CHROME.EXE", true},
+		{"chrome.exe", "chrome_exe", false}, // '.' is not a wildcard
+		{"*Jira*", "PROJ-1 - Jira - Chrome", true},
+		{"*Jira*", "Confluence", false},
+		{"Note?ad", "Notepad", true},
+		{"Note?ad", "Notepaad", false},
+		{"a(b)+", "a(b)+", true},
+	}
+	for _, tt := range tests {
+		re, err := glob(tt.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := re.MatchString(tt.s); got != tt.want {
+			t.Errorf("glob(%s) matches '%s': %t, want %t", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+	if re, err := glob(""); re != nil || err != nil {
+		t.Errorf("glob of an empty pattern = %v, %v, want nil", re, err)
+	}
+}
+
+func TestRuleFlag(t *testing.T) {
+	r := testRule(t, "exclude", "exe:chrome.exe,title:*Jira*,regex:^PROJ-\\d+,monitor:2,class:Chrome_WidgetWin_1")
+	want := rule{Action: "exclude", Exe: "chrome.exe", Class: "Chrome_WidgetWin_1", Title: "*Jira*", TitleRegex: `^PROJ-\d+`, Monitor: 2}
+	if r.Action != want.Action || r.Exe != want.Exe || r.Class != want.Class || r.Title != want.Title || r.TitleRegex != want.TitleRegex || r.Monitor != want.Monitor {
+		t.Errorf("rule %+v, want %+v", r, want)
+	}
+	for spec, msg := range map[string]string{
+		"chrome.exe":     "criterion 'chrome.exe' is not",
+		"pid:12":         "unknown criterion 'pid'",
+		"monitor:0":      "monitor '0' is not a rank from 1",
+		"monitor:second": "monitor 'second' is not a rank from 1",
+		"regex:(":        "missing closing )",
+		"title:":         "rule without any criterion",
+	} {
+		f := &ruleFlag{action: "include"}
+		if err := f.Set(spec); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("--only %s: error %v, want %q", spec, err, msg)
+		}
+	}
+}
+
+func TestRuleMatches(t *testing.T) {
+	chrome := &window{Name: "PROJ-12 - Jira - Chrome", Class: "Chrome_WidgetWin_1", Exe: `C:\Program Files\Google\Chrome\chrome.exe`, R: rect{2000, 0, 2800, 600}}
+	tests := []struct {
+		spec string
+		want bool
+	}{
+		{"exe:chrome.exe", true},
+		{`exe:C:\Program Files\*`, true},
+		{"exe:firefox.exe", false},
+		{"class:chrome_*", true},
+		{"title:*jira*", true},
+		{"title:Jira", false},
+		{`regex:^PROJ-\d+ `, true},
+		{`regex:^proj`, false}, // regexes are case-sensitive
+		{"monitor:2", true},
+		{"monitor:1", false},
+		{"exe:chrome.exe,monitor:1", false},
+	}
+	for _, tt := range tests {
+		if got := testRule(t, "include", tt.spec).matches(chrome, testMonitors()); got != tt.want {
+			t.Errorf("%s matches: %t, want %t", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestSelection(t *testing.T) {
+	windows := []*window{
+		{Name: "a", Exe: `C:\a.exe`},
+		{Name: "b", Exe: `C:\b.exe`},
+		{Name: "b (private)", Exe: `C:\b.exe`},
+		{Name: "c", Exe: `C:\c.exe`},
+	}
+	rules := func(action string, specs ...string) []*rule {
+		l := make([]*rule, 0, len(specs))
+		for _, spec := range specs {
+			l = append(l, testRule(t, action, spec))
+		}
+		return l
+	}
+	tests := []struct {
+		name             string
+		include, exclude []*rule
+		want             string
+	}{
+		{"no rule", nil, nil, "a,b,b (private),c"},
+		{"include", rules("include", "exe:a.exe", "exe:b.exe"), nil, "a,b,b (private)"},
+		{"exclude", nil, rules("exclude", "title:*private*"), "a,b,c"},
+		{"include and exclude", rules("include", "exe:b.exe"), rules("exclude", "title:*private*"), "b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sel := &selection{include: tt.include, exclude: tt.exclude}
+			names := make([]string, 0)
+			for _, w := range sel.filter(windows, nil) {
+				names = append(names, w.Name)
+			}
+			if got := strings.Join(names, ","); got != tt.want {
+				t.Errorf("selected %s, want %s", got, tt.want)
+			}
+		})
+	}
+	var none *selection
+	if got := none.filter(windows, nil); len(got) != len(windows) {
+		t.Errorf("nil selection selects %d windows, want all", len(got))
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	dir := t.TempDir()
+	sel, err := loadRules(filepath.Join(dir, rulesFile))
+	if err != nil || len(sel.include) != 0 || len(sel.exclude) != 0 {
+		t.Errorf("missing rules file: %+v, %v, want no rule", sel, err)
+	}
+	path := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(path, []byte(`[{"Action": "ignore", "Exe": "a.exe"}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadRules(path); err == nil || !strings.Contains(err.Error(), "invalid rule 1") {
+		t.Errorf("error %v, want the invalid rule 1", err)
+	}
+}
+
+func TestSelectionFlags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), rulesFile)
+	rules := `[
+		{"Action": "include", "Exe": "a.exe"},
+		{"Action": "include", "Exe": "b.exe"},
+		{"Action": "exclude", "Class": "Tooltip"}
+	]`
+	if err := os.WriteFile(path, []byte(rules), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	setConfig(t, func(c *config) { c.Filters.Rules = path })
+	fs := flag.NewFlagSet("record", flag.ContinueOnError)
+	selected := selectionFlags(fs)
+	if err := fs.Parse([]string{"--only", "exe:c.exe", "--exclude", "title:*private*", "--exclude", "monitor:2"}); err != nil {
+		t.Fatal(err)
+	}
+	sel, err := selected()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// --only replaces the include rules, --exclude adds to the exclude ones
+	if len(sel.include) != 1 || sel.include[0].Exe != "c.exe" || len(sel.exclude) != 3 {
+		t.Errorf("selection of %d include rule(s) and %d exclude rule(s), want 1 and 3", len(sel.include), len(sel.exclude))
+	}
+}
+
+func TestRecordSelection(t *testing.T) {
+	d := testDesktop(
+		&fakeWindow{Hwnd: 1, Name: "a", Exe: `C:\a.exe`, Visible: true},
+		&fakeWindow{Hwnd: 2, Name: "b", Exe: `C:\b.exe`, Visible: true, R: rect{2000, 0, 2800, 600}},
+	)
+	st := &store{dir: t.TempDir()}
+	if _, err := record(d, st, "work", &selection{exclude: []*rule{testRule(t, "exclude", "monitor:2")}}); err != nil {
+		t.Fatal(err)
+	}
+	var lay layout
+	if err := st.load("work", &lay); err != nil {
+		t.Fatal(err)
+	}
+	if len(lay.Windows) != 1 || lay.Windows[0].Name != "a" {
+		t.Errorf("recorded %v, want a only", lay.Windows)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"time"
+)
+
+func cmdList(st *store, fs *flag.FlagSet, args []string) (result, error) {
+	if pos, err := parseArgs(fs, args); err != nil || len(pos) != 0 {
+		return nil, errUsage
+	}
+	return list(st)
+}
+
+func cmdShow(st *store, fs *flag.FlagSet, args []string) (result, error) {
+	name, err := layoutArg(fs, args, false)
+	if err != nil {
+		return nil, err
+	}
+	return show(st, name)
+}
+
+func cmdHistory(st *store, fs *flag.FlagSet, args []string) (result, error) {
+	name, err := layoutArg(fs, args, false)
+	if err != nil {
+		return nil, err
+	}
+	return history(st, name)
+}
+
+func cmdDelete(st *store, fs *flag.FlagSet, args []string) (result, error) {
+	name, err := layoutArg(fs, args, true)
+	if err != nil {
+		return nil, err
+	}
+	return nil, st.delete(name)
+}
+
+// listResult is what list reports.
+type listResult struct {
+	Layouts []storedLayout
+}
+
+func (r *listResult) printText(w io.Writer) {
+	for _, sl := range r.Layouts {
+		fmt.Fprintf(w, "%-30s %s\n", sl.Name, sl.Modified.Format("2006-01-02 15:04:05"))
+	}
+}
+
+func list(st *store) (*listResult, error) {
+	l, err := st.list()
+	if err != nil {
+		return nil, err
+	}
+	return &listResult{Layouts: l}, nil
+}
+
+// showResult is what show reports: the layout as recorded.
+type showResult struct {
+	Layout string
+	layout
+}
+
+func (r *showResult) printText(w io.Writer) {
+	fmt.Fprintf(w, "Layout '%s': %d monitor(s), %d window(s), recorded %s", r.Layout, len(r.Monitors), len(r.Windows),
+		r.Created.Local().Format("2006-01-02 15:04:05"))
+	if r.User != "" || r.Host != "" {
+		fmt.Fprintf(w, " by %s on %s", r.User, r.Host)
+	}
+	fmt.Fprintln(w)
+	for i, m := range r.Monitors {
+		primary := ""
+		if m.Primary {
+			primary = " primary"
+		}
+		fmt.Fprintf(w, "  monitor %d: %-14s %s work %s %d DPI (%d%%)%s\n", i+1, m.Device, m.R, m.Work, m.DPI, scale(m.DPI), primary)
+	}
+	for _, sw := range r.Windows {
+		fmt.Fprintf(w, "  %-40s %-20s %s", sw.Name, sw.Class, sw.placement())
+		if sw.DPI != 0 {
+			fmt.Fprintf(w, " %d DPI", sw.DPI)
+		}
+		fmt.Fprintln(w)
+		if sw.PID != 0 {
+			fmt.Fprintf(w, "      pid %d", sw.PID)
+			if sw.AppID != "" {
+				fmt.Fprintf(w, ", app %s", sw.AppID)
+			}
+			fmt.Fprintln(w)
+		}
+		if sw.Exe != "" {
+			fmt.Fprintf(w, "      exe %s\n", sw.Exe)
+		}
+		if sw.Cmdline != "" {
+			fmt.Fprintf(w, "      cmd %s\n", sw.Cmdline)
+		}
+		if sw.Dir != "" {
+			fmt.Fprintf(w, "      dir %s\n", sw.Dir)
+		}
+	}
+}
+
+func show(st *store, name string) (*showResult, error) {
+	r := &showResult{Layout: name}
+	if err := st.load(name, &r.layout); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// historyResult is what history reports.
+type historyResult struct {
+	Layout    string
+	Snapshots []snapshotInfo // the latest first
+}
+
+type snapshotInfo struct {
+	Rank     int
+	Time     time.Time
+	Monitors int
+	Windows  int
+}
+
+func (r *historyResult) printText(w io.Writer) {
+	fmt.Fprintf(w, "Layout '%s': %d snapshot(s)\n", r.Layout, len(r.Snapshots))
+	for _, sn := range r.Snapshots {
+		fmt.Fprintf(w, "  %3d  %s  %d monitor(s), %d window(s)\n", sn.Rank,
+			sn.Time.Local().Format("2006-01-02 15:04:05"), sn.Monitors, sn.Windows)
+	}
+}
+
+func history(st *store, name string) (*historyResult, error) {
+	l, err := st.history(name)
+	if err != nil {
+		return nil, err
+	}
+	r := &historyResult{Layout: name, Snapshots: make([]snapshotInfo, 0, len(l))}
+	for i, sn := range l {
+		var lay layout
+		if err := loadLayout(sn.path, &lay); err != nil {
+			return nil, err
+		}
+		r.Snapshots = append(r.Snapshots, snapshotInfo{Rank: i, Time: sn.Time, Monitors: len(lay.Monitors), Windows: len(lay.Windows)})
+	}
+	return r, nil
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const storeLock = ".lock" // lock file of the store, see store.lock
+
+// store holds the named layouts, one file per layout, in any format,
+// in the per-user configuration directory:
+// %APPDATA%\winpos\layouts on Windows, $XDG_CONFIG_HOME/winpos/layouts elsewhere.
+type store struct {
+	dir string
+}
+
+// configDir is the per-user winpos directory,
+// holding by default the configuration, the layouts and the rules file.
+func configDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "winpos"), nil
+}
+
+func defaultStore() (*store, error) {
+	if conf.Store != "" {
+		return &store{dir: conf.Store}, nil
+	}
+	dir, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+	return &store{dir: filepath.Join(dir, "layouts")}, nil
+}
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*$`)
+
+// path returns the file of the layout name in the Format setting,
+// to write it.
+func (s *store) path(name string) (string, error) {
+	if !validName.MatchString(name) {
+		return "", fmt.Errorf("invalid layout name '%s': use letters, digits, '-', '_' and '.'", name)
+	}
+	return filepath.Join(s.dir, name+codecByName(conf.Format).ext()), nil
+}
+
+// files returns the existing files of the layout name,
+// whatever their format, JSON first.
+func (s *store) files(name string) ([]string, error) {
+	if _, err := s.path(name); err != nil {
+		return nil, err
+	}
+	var l []string
+	for _, c := range codecs {
+		for _, ext := range c.exts {
+			path := filepath.Join(s.dir, name+ext)
+			if _, err := os.Stat(path); err == nil {
+				l = append(l, path)
+			}
+		}
+	}
+	return l, nil
+}
+
+// file returns the existing file of the layout name,
+// else the one to write.
+func (s *store) file(name string) (string, error) {
+	l, err := s.files(name)
+	if err != nil || len(l) == 0 {
+		return s.path(name)
+	}
+	return l[0], nil
+}
+
+// lock waits for the other winpos processes to be done with the store,
+// like a scheduled record and one by the user, and locks it for this one.
+func (s *store) lock() (*fileLock, error) {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return nil, err
+	}
+	return lockFile(filepath.Join(s.dir, storeLock))
+}
+
+// save saves lay as the layout name, in the Format setting, and as its
+// latest snapshot, unless it records the same as the saved one.
+// The file of the layout in another format, if any, is replaced.
+func (s *store) save(name string, lay *layout) error {
+	return s.saveIn(name, codecByName(conf.Format), lay)
+}
+
+// saveIn saves lay as the layout name in the format of c, like save.
+func (s *store) saveIn(name string, c *codec, lay *layout) (err error) {
+	l, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if uerr := l.unlock(); err == nil {
+			err = uerr
+		}
+	}()
+	return s.saveLocked(name, c, lay)
+}
+
+// saveLocked is saveIn, the store being locked by the caller.
+func (s *store) saveLocked(name string, c *codec, lay *layout) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	path = strings.TrimSuffix(path, filepath.Ext(path)) + c.ext()
+	if err := lay.validate(); err != nil {
+		return fmt.Errorf("invalid layout '%s': %v", name, err)
+	}
+	olds, err := s.files(name)
+	if err != nil {
+		return err
+	}
+	var old layout
+	if len(olds) == 1 && olds[0] == path && loadLayout(path, &old) == nil && old.sameAs(lay) {
+		return nil
+	}
+	if err := Save(path, lay); err != nil {
+		return err
+	}
+	for _, p := range olds {
+		if p != path {
+			if err := os.Remove(p); err != nil {
+				return err
+			}
+		}
+	}
+	return s.saveSnapshot(name, time.Now(), lay)
+}
+
+func (s *store) load(name string, lay *layout) error {
+	path, err := s.file(name)
+	if err != nil {
+		return err
+	}
+	if err := loadLayout(path, lay); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no layout '%s' (see 'winpos list')", name)
+		}
+		return err
+	}
+	return nil
+}
+
+// loadAt loads the snapshot of the layout designated by at
+// (see snapshotAt), or the layout itself when at is empty.
+func (s *store) loadAt(name, at string, lay *layout) error {
+	if at == "" {
+		return s.load(name, lay)
+	}
+	sn, err := s.snapshotAt(name, at)
+	if err != nil {
+		return err
+	}
+	return loadLayout(sn.path, lay)
+}
+
+func (s *store) exists(name string) bool {
+	l, err := s.files(name)
+	return err == nil && len(l) > 0
+}
+
+func (s *store) delete(name string) (err error) {
+	if _, err := s.path(name); err != nil {
+		return err
+	}
+	l, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if uerr := l.unlock(); err == nil {
+			err = uerr
+		}
+	}()
+	paths, err := s.files(name)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no layout '%s' (see 'winpos list')", name)
+	}
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(s.historyDir(name))
+}
+
+// rename renames the layout name, with its history.
+func (s *store) rename(name, to string) (err error) {
+	if _, err := s.path(to); err != nil {
+		return err
+	}
+	l, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if uerr := l.unlock(); err == nil {
+			err = uerr
+		}
+	}()
+	paths, err := s.files(name)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no layout '%s' (see 'winpos list')", name)
+	}
+	if s.exists(to) {
+		return fmt.Errorf("layout '%s' already exists", to)
+	}
+	for _, path := range paths {
+		if err := os.Rename(path, filepath.Join(s.dir, to+filepath.Ext(path))); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(s.historyDir(name), s.historyDir(to)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return syncDir(s.dir)
+}
+
+type storedLayout struct {
+	Name     string
+	Modified time.Time
+}
+
+// list returns the stored layouts, sorted by name.
+// A layout in several formats, left by hand, is listed once.
+func (s *store) list() ([]storedLayout, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return make([]storedLayout, 0), nil
+		}
+		return nil, err
+	}
+	l := make([]storedLayout, 0, len(entries))
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		name, c := splitExt(e.Name())
+		if e.IsDir() || c == nil || !validName.MatchString(name) || seen[name] {
+			continue
+		}
+		seen[name] = true
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, storedLayout{Name: name, Modified: info.ModTime()})
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i].Name < l[j].Name })
+	return l, nil
+}
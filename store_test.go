@@ -0,0 +1,212 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStorePath(t *testing.T) {
+	st := &store{dir: "layouts"}
+	for _, name := range []string{"work", "home-2", "dock_station.v2", "3"} {
+		if path, err := st.path(name); err != nil || path != filepath.Join("layouts", name+".json") {
+			t.Errorf("path(%s) = %s, %v", name, path, err)
+		}
+	}
+	for _, name := range []string{"", ".hidden", "-work", "a/b", `a\b`, "..", "work space"} {
+		if _, err := st.path(name); err == nil || !strings.Contains(err.Error(), "invalid layout name") {
+			t.Errorf("path(%q): error %v, want an invalid name", name, err)
+		}
+	}
+}
+
+func TestRecordRestore(t *testing.T) {
+	st := &store{dir: filepath.Join(t.TempDir(), "layouts")}
+	d := testDesktop(&fakeWindow{Hwnd: 1, Name: "a", Visible: true}, &fakeWindow{Hwnd: 2, Name: "b", Visible: true, Maximize: true})
+	if _, err := record(d, st, "work", nil); err != nil {
+		t.Fatal(err)
+	}
+	d.Wins[0].R = rect{0, 0, 10, 10}
+	d.Wins[1].Maximize = false
+	if _, err := restore(d, st, "work", restoreOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, fw, _ := d.find(1); fw.R != (rect{100, 100, 900, 700}) {
+		t.Errorf("window a at %v, want back at (100,100)", fw.R)
+	}
+	if _, fw, _ := d.find(2); !fw.Maximize {
+		t.Errorf("window b not maximized back")
+	}
+	if _, err := restore(d, st, "home", restoreOptions{}); err == nil || !strings.Contains(err.Error(), "no layout 'home'") {
+		t.Errorf("error %v restoring a missing layout", err)
+	}
+}
+
+func TestStoreSaveLoad(t *testing.T) {
+	st := &store{dir: filepath.Join(t.TempDir(), "layouts")}
+	lay := newLayout(testMonitors(), []*window{{Name: "a", Class: "A", R: rect{0, 0, 800, 600}}})
+	if err := st.save("work", lay); err != nil {
+		t.Fatal(err)
+	}
+	var got layout
+	if err := st.load("work", &got); err != nil {
+		t.Fatal(err)
+	}
+	if !got.sameAs(lay) {
+		t.Errorf("loaded %+v, want %+v", got, lay)
+	}
+	if err := st.load("home", &got); err == nil || !strings.Contains(err.Error(), "no layout 'home'") {
+		t.Errorf("error %v loading a missing layout", err)
+	}
+	// saved in another format, the layout replaces its previous file
+	setConfig(t, func(c *config) { c.Format = tomlCodec.name })
+	if err := st.save("work", lay); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := st.files("work"); len(files) != 1 || filepath.Ext(files[0]) != ".toml" {
+		t.Errorf("files %v, want the TOML one only", files)
+	}
+}
+
+func TestStoreList(t *testing.T) {
+	st := &store{dir: t.TempDir()}
+	for _, name := range []string{"work.json", "home.json", "notes.txt", "-x.json"} {
+		if err := os.WriteFile(filepath.Join(st.dir, name), []byte("[]"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(st.dir, "old.json"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	l, err := st.list()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(l))
+	for i, sl := range l {
+		names[i] = sl.Name
+	}
+	if got := strings.Join(names, ","); got != "home,work" {
+		t.Errorf("listed %s, want home,work", got)
+	}
+	if l, err := (&store{dir: filepath.Join(st.dir, "none")}).list(); len(l) != 0 || err != nil {
+		t.Errorf("listed %v, %v in a missing directory", l, err)
+	}
+}
+
+func TestStoreRenameDelete(t *testing.T) {
+	st := &store{dir: t.TempDir()}
+	lay := newLayout(testMonitors(), []*window{importedWindow("a", "A", "", rect{0, 0, 800, 600}, stateNormal)})
+	for _, name := range []string{"work", "home"} {
+		if err := st.save(name, lay); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.rename("work", "home"); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("error %v renaming over a layout", err)
+	}
+	if err := st.rename("work", "office"); err != nil {
+		t.Fatal(err)
+	}
+	if st.exists("work") || !st.exists("office") {
+		t.Errorf("layout not renamed")
+	}
+	if l, _ := st.history("office"); len(l) != 1 {
+		t.Errorf("%d snapshot(s) renamed, want 1", len(l))
+	}
+	if err := st.delete("office"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(st.historyDir("office")); st.exists("office") || !os.IsNotExist(err) {
+		t.Errorf("layout or history left after delete: %v", err)
+	}
+	if err := st.delete("office"); err == nil {
+		t.Errorf("deleted a missing layout")
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "work.json")
+	if err := writeFile(path, []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	// a failed write leaves the file as it was, and no temporary file
+	if err := os.Mkdir(filepath.Join(dir, "home.json"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFile(filepath.Join(dir, "home.json"), []byte("{}")); err == nil {
+		t.Errorf("wrote over a directory")
+	}
+	if err := writeFile(path, []byte(`{"Version": 2}`)); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != `{"Version": 2}` {
+		t.Errorf("read %s after the second write", b)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("%d files, want work.json and home.json only", len(entries))
+	}
+}
+
+func TestStoreLock(t *testing.T) {
+	st := &store{dir: filepath.Join(t.TempDir(), "layouts")}
+	l, err := st.lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	locked := make(chan error)
+	go func() {
+		l, err := st.lock()
+		if err == nil {
+			err = l.unlock()
+		}
+		locked <- err
+	}()
+	select {
+	case <-locked:
+		t.Fatal("store locked twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := l.unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-locked; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreSaveConcurrent(t *testing.T) {
+	st := &store{dir: t.TempDir()}
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			lay := newLayout(testMonitors(), []*window{{Name: "a", Class: "A", R: rect{0, 0, 800 + int32(i), 600}}})
+			errs <- st.save("work", lay)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	var lay layout
+	if err := st.load("work", &lay); err != nil {
+		t.Fatalf("layout saved concurrently unreadable: %v", err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(st.dir, "*.tmp")); len(files) != 0 {
+		t.Errorf("temporary files left: %v", files)
+	}
+}
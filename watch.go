@@ -0,0 +1,121 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// watchPrefix names the layouts snapshotted by watch, one per monitor
+// topology: watchPrefix + the fingerprint of the monitors.
+const watchPrefix = "watch-"
+
+func cmdWatch(st *store, fs *flag.FlagSet, args []string) error {
+	interval := fs.Duration("interval", time.Duration(conf.Watch.Interval), "time between two snapshots of the windows")
+	debounce := fs.Duration("debounce", time.Duration(conf.Watch.Debounce), "time the displays must be stable before restoring")
+	selected := selectionFlags(fs)
+	if pos, err := parseArgs(fs, args); err != nil || len(pos) != 0 {
+		return errUsage
+	}
+	sel, err := selected()
+	if err != nil {
+		return err
+	}
+	ws, err := newWindowSystem()
+	if err != nil {
+		return err
+	}
+	stop := make(chan struct{})
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt)
+	go func() {
+		<-interrupted
+		close(stop)
+	}()
+	return watch(ws, st, sel, *interval, *debounce, stop)
+}
+
+// watch snapshots the windows every interval, in the layout of the current
+// monitor topology. When the displays change, it waits for them to be stable
+// for debounce, then restores the snapshot of the new topology, if any.
+func watch(ws WindowSystem, st *store, sel *selection, interval, debounce time.Duration, stop <-chan struct{}) error {
+	changes, err := ws.DisplayChanges(stop)
+	if err != nil {
+		return err
+	}
+	monitors, err := ws.Monitors()
+	if err != nil {
+		return err
+	}
+	current := fingerprint(monitors)
+	log.Printf("Winpos watch: %d monitor(s), layout '%s'", len(monitors), watchPrefix+current)
+
+	snapshot := func() {
+		if _, err := record(ws, st, watchPrefix+current, sel); err != nil {
+			log.Printf("Winpos watch: snapshot failed: %v", err)
+		}
+	}
+	snapshot()
+
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	settle := time.NewTimer(debounce)
+	settle.Stop()
+	settling := false
+	startSettling := func() {
+		if !settle.Stop() {
+			select {
+			case <-settle.C:
+			default:
+			}
+		}
+		settle.Reset(debounce)
+		settling = true
+	}
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-changes:
+			startSettling()
+		case <-tick.C:
+			if settling {
+				continue
+			}
+			// Do not snapshot a topology change not notified yet
+			// into the layout of the previous topology.
+			if monitors, err := ws.Monitors(); err != nil || fingerprint(monitors) != current {
+				startSettling()
+				continue
+			}
+			snapshot()
+		case <-settle.C:
+			settling = false
+			monitors, err := ws.Monitors()
+			if err != nil {
+				log.Printf("Winpos watch: %v", err)
+				continue
+			}
+			fp := fingerprint(monitors)
+			if fp == current {
+				continue
+			}
+			current = fp
+			name := watchPrefix + current
+			log.Printf("Winpos watch: %d monitor(s), layout '%s'", len(monitors), name)
+			if !st.exists(name) {
+				log.Printf("Winpos watch: no snapshot yet for these monitors")
+				continue
+			}
+			r, err := restore(ws, st, name, restoreOptions{sel: sel})
+			if err != nil {
+				log.Printf("Winpos watch: restore failed: %v", err)
+				continue
+			}
+			r.printText(os.Stdout)
+		}
+	}
+}
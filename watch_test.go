@@ -0,0 +1,120 @@
+package main
+
+import (
+	"io"
+	"log"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockedDesktop is a fake desktop shared by watch and the test,
+// which docks and undocks it.
+type lockedDesktop struct {
+	mu sync.Mutex
+	d  *fakeDesktop
+}
+
+func (l *lockedDesktop) do(f func(d *fakeDesktop)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f(l.d)
+}
+
+func (l *lockedDesktop) Windows() (ws []*window, err error) {
+	l.do(func(d *fakeDesktop) { ws, err = d.Windows() })
+	return
+}
+
+func (l *lockedDesktop) Monitors() (ms []*monitor, err error) {
+	l.do(func(d *fakeDesktop) { ms, err = d.Monitors() })
+	return
+}
+
+func (l *lockedDesktop) Placement(h hwnd) (p placement, err error) {
+	l.do(func(d *fakeDesktop) { p, err = d.Placement(h) })
+	return
+}
+
+func (l *lockedDesktop) SetPlacement(h hwnd, p placement) (err error) {
+	l.do(func(d *fakeDesktop) { err = d.SetPlacement(h, p) })
+	return
+}
+
+func (l *lockedDesktop) Restack(order []hwnd) (err error) {
+	l.do(func(d *fakeDesktop) { err = d.Restack(order) })
+	return
+}
+
+func (l *lockedDesktop) Foreground() (h hwnd) {
+	l.do(func(d *fakeDesktop) { h = d.Foreground() })
+	return
+}
+
+func (l *lockedDesktop) Focus(h hwnd) (err error) {
+	l.do(func(d *fakeDesktop) { err = d.Focus(h) })
+	return
+}
+
+func (l *lockedDesktop) Launch(w *window) (err error) {
+	l.do(func(d *fakeDesktop) { err = d.Launch(w) })
+	return
+}
+
+func (l *lockedDesktop) DisplayChanges(stop <-chan struct{}) (c <-chan struct{}, err error) {
+	l.do(func(d *fakeDesktop) { c, err = d.DisplayChanges(stop) })
+	return
+}
+
+func TestWatch(t *testing.T) {
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(out) })
+	d := testDesktop(&fakeWindow{Hwnd: 1, Name: "a - Term", Class: "Term", Visible: true})
+	// undocked: the layout of the two monitors is restored when docked
+	docked := d.Displays
+	d.Displays = docked[:1]
+	st := &store{dir: t.TempDir()}
+	want := rect{2000, 100, 2800, 700}
+	lay := newLayout(docked, []*window{{Name: "a - Term", Class: "Term", R: want}})
+	if err := st.save(watchPrefix+fingerprint(docked), lay); err != nil {
+		t.Fatal(err)
+	}
+	ws := &lockedDesktop{d: d}
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- watch(ws, st, nil, 10*time.Millisecond, 20*time.Millisecond, stop)
+	}()
+	undocked := watchPrefix + fingerprint(docked[:1])
+	wait := func(what string, cond func() bool) {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				close(stop)
+				<-done
+				t.Fatalf("watch never %s", what)
+			}
+		}
+	}
+	wait("snapshotted the undocked windows", func() bool { return st.exists(undocked) })
+	ws.do(func(d *fakeDesktop) { d.SetDisplays(docked) })
+	wait("restored the docked layout", func() bool {
+		var r rect
+		ws.do(func(d *fakeDesktop) { _, fw, _ := d.find(1); r = fw.R })
+		return r == want
+	})
+	close(stop)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	var snap layout
+	if err := st.load(undocked, &snap); err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range snap.Windows {
+		if w.R != (rect{100, 100, 900, 700}) {
+			t.Errorf("undocked snapshot of %s at %s: docked windows recorded in it", w.Name, w.R)
+		}
+	}
+}
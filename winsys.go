@@ -0,0 +1,213 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// hwnd is a native window handle.
+// It is only meaningful for the WindowSystem which returned it.
+type hwnd uintptr
+
+// rect mirrors the Win32 RECT, so that layouts recorded with
+// win.RECT keep decoding the same way.
+type rect struct {
+	Left, Top, Right, Bottom int32
+}
+
+func (r rect) width() int32  { return r.Right - r.Left }
+func (r rect) height() int32 { return r.Bottom - r.Top }
+
+func (r rect) String() string {
+	return fmt.Sprintf("(%d,%d) %dx%d", r.Left, r.Top, r.width(), r.height())
+}
+
+// near tells whether r and q differ by a pixel at most on each side.
+func (r rect) near(q rect) bool {
+	d := func(a, b int32) bool { return a-b <= 1 && b-a <= 1 }
+	return d(r.Left, q.Left) && d(r.Top, q.Top) && d(r.Right, q.Right) && d(r.Bottom, q.Bottom)
+}
+
+type monitor struct {
+	Device   string // GDI device name, like \\.\DISPLAY1
+	DeviceID string `json:",omitempty"` // device interface path of the monitor
+	Serial   string `json:",omitempty"` // EDID serial number
+	R        rect   // bounds, in virtual screen coordinates
+	Work     rect   // work area: bounds minus taskbar and docked toolbars
+	DPI      uint32 // effective DPI, 96 at 100% scaling
+	Primary  bool
+}
+
+type point struct {
+	X, Y int32
+}
+
+// Window states, as the showCmd of a WINDOWPLACEMENT.
+const (
+	stateNormal    = "normal"
+	stateMinimized = "minimized"
+	stateMaximized = "maximized"
+)
+
+// placement is the position and state of a window, like a WINDOWPLACEMENT,
+// but in screen coordinates.
+type placement struct {
+	State  string // stateNormal, stateMinimized or stateMaximized
+	Normal rect   // position when neither minimized nor maximized
+	// R is the actual window rect. For a normal window, it differs from
+	// Normal when the window is snapped or arranged.
+	R rect
+	// MinPos and MaxPos are the upper-left corners of the window when
+	// minimized and maximized, (-1,-1) to let Windows choose.
+	MinPos, MaxPos point
+	// RestoreMaximized is set for a window minimized from the maximized state.
+	RestoreMaximized bool `json:",omitempty"`
+}
+
+// snapped tells a normal window snapped or arranged,
+// out of its normal position.
+func (p placement) snapped() bool {
+	return p.State == stateNormal && p.R != p.Normal
+}
+
+// same tells whether two placements show the window the same way.
+func (p placement) same(q placement) bool {
+	if p.State != q.State || p.Normal != q.Normal {
+		return false
+	}
+	return !p.snapped() && !q.snapped() || p.R == q.R
+}
+
+func (p placement) String() string {
+	switch {
+	case p.snapped():
+		return p.R.String() + " snapped, normal " + p.Normal.String()
+	case p.State == stateNormal:
+		return p.R.String()
+	}
+	return p.State + ", normal " + p.Normal.String()
+}
+
+// reached tells whether a window placed at p actually got to it,
+// give or take a pixel of rounding.
+func (p placement) reached(got placement) bool {
+	if p.State != got.State || !p.Normal.near(got.Normal) {
+		return false
+	}
+	return !p.snapped() || p.R.near(got.R)
+}
+
+// Why a window could not be placed, wrapped by the SetPlacement errors.
+var (
+	errAccessDenied = errors.New("access denied") // elevated window, see User Interface Privilege Isolation
+	errVanished     = errors.New("window vanished")
+	errRejected     = errors.New("placement rejected") // possibly transient
+)
+
+// Window styles used to select the windows worth recording.
+// https://docs.microsoft.com/en-us/windows/win32/winmsg/window-styles
+const (
+	wsMaximize = 0x01000000
+	wsCaption  = 0x10C00000 // WS_VISIBLE | WS_CAPTION
+)
+
+// WindowSystem is the desktop winpos records and restores.
+// The user32 one drives the actual Windows desktop, the fake one
+// is an in-memory desktop, used off Windows.
+type WindowSystem interface {
+	// Windows lists all top-level windows, in EnumWindows (z-)order,
+	// with the process of only those which are application windows.
+	Windows() ([]*window, error)
+	// Monitors lists the active display monitors.
+	Monitors() ([]*monitor, error)
+	// Placement returns the current position and state of a window.
+	Placement(h hwnd) (placement, error)
+	// SetPlacement moves a window and applies its state. Its errors wrap
+	// errAccessDenied, errVanished or errRejected.
+	SetPlacement(h hwnd, p placement) error
+	// Restack puts windows at the top of the z-order, in one batch,
+	// the first one topmost, without activating them.
+	Restack(order []hwnd) error
+	// Foreground returns the foreground window, 0 if none.
+	Foreground() hwnd
+	// Focus makes a window the foreground one.
+	Focus(h hwnd) error
+	// Launch starts the application of a recorded window, from its
+	// AppID, or its executable, command line and working directory.
+	Launch(w *window) error
+	// DisplayChanges notifies the changes of display configuration
+	// (monitor plugged, unplugged, resolution change), until stop is closed.
+	DisplayChanges(stop <-chan struct{}) (<-chan struct{}, error)
+}
+
+type window struct {
+	Hwnd        hwnd
+	Name, Class string
+	// Process metadata, empty when it cannot be queried (elevated process, ...)
+	PID     uint32 `json:",omitempty"`
+	Exe     string `json:",omitempty"` // full path of the process executable
+	Cmdline string `json:",omitempty"` // command line of the process
+	AppID   string `json:",omitempty"` // explicit AppUserModelID of the window
+	// Dir is the working directory of the process, to launch the application
+	// in rather than the directory of its executable.
+	Dir string `json:",omitempty"`
+	// TitleRegex, set by hand in a layout, matches the title of the
+	// live window to restore, when it varies (document name, ...).
+	TitleRegex string `json:",omitempty"`
+	R          rect   // actual window rect, in physical pixels
+	// DPI is the one of the monitor of the window for DPI-aware applications,
+	// 96 for the others, which Windows scales itself.
+	DPI uint32 `json:",omitempty"`
+	// Placement is nil in layouts recorded before it was.
+	Placement *placement `json:",omitempty"`
+	// Z is the rank of the window in the z-order, 0 for the topmost one.
+	Z int
+	// Foreground is set for the foreground window at the time of the record.
+	Foreground bool `json:",omitempty"`
+	visible    bool
+	Maximize   bool
+	hasChild   bool
+	Style      int32
+	Caption    bool
+}
+
+// placement returns the recorded placement of the window,
+// or the one of its rect and style for older layouts.
+func (w *window) placement() placement {
+	if w.Placement != nil {
+		return *w.Placement
+	}
+	p := placement{State: stateNormal, Normal: w.R, R: w.R, MinPos: point{-1, -1}, MaxPos: point{-1, -1}}
+	if w.Maximize {
+		p.State = stateMaximized
+	}
+	return p
+}
+
+// application tells whether a window is a main application window,
+// of the visibility, style and title of the Filters setting.
+func (w *window) application() bool {
+	style := int32(conf.Filters.Style)
+	return w.Style&style == style && w.visible && (w.Name != "" || !conf.Filters.RequireTitle)
+}
+
+// listWindows returns the main application windows of ws.
+func listWindows(ws WindowSystem) ([]*window, error) {
+	all, err := ws.Windows()
+	if err != nil {
+		return nil, err
+	}
+	foreground := ws.Foreground()
+	l := make([]*window, 0)
+	for _, w := range all {
+		// https://stackoverflow.com/questions/21503109/how-to-use-enumwindows-to-get-only-actual-application-windows
+		w.Maximize = ((w.Style & wsMaximize) == wsMaximize)
+		w.Caption = ((w.Style & wsCaption) == wsCaption)
+		if w.application() {
+			w.Z = len(l)
+			w.Foreground = w.Hwnd == foreground
+			l = append(l, w)
+		}
+	}
+	return l, nil
+}
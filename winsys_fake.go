@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// fakeDesktop is an in-memory WindowSystem.
+// It can be loaded from a JSON description, see loadFakeDesktop.
+type fakeDesktop struct {
+	Displays []*monitor
+	Wins     []*fakeWindow // z-order, top first
+	Active   hwnd          // the foreground window
+
+	changes chan struct{}
+}
+
+type fakeWindow struct {
+	Hwnd     hwnd
+	Name     string
+	Class    string
+	PID      uint32
+	Exe      string
+	Cmdline  string
+	Dir      string
+	AppID    string
+	R        rect
+	Normal   rect // R when not set
+	Visible  bool
+	Maximize bool
+	Minimize bool
+	Style    int32
+	DPI      uint32 // of its monitor when not set
+	Elevated bool   // refuses to be placed
+	Fixed    bool   // accepts to be placed, but stays where it is
+}
+
+func (fw *fakeWindow) placement() placement {
+	p := placement{State: stateNormal, Normal: fw.Normal, R: fw.R, MinPos: point{-1, -1}, MaxPos: point{-1, -1}}
+	if p.Normal == (rect{}) {
+		p.Normal = fw.R
+	}
+	switch {
+	case fw.Minimize:
+		p.State = stateMinimized
+	case fw.Maximize:
+		p.State = stateMaximized
+	}
+	return p
+}
+
+func (d *fakeDesktop) find(h hwnd) (int, *fakeWindow, error) {
+	for i, fw := range d.Wins {
+		if fw.Hwnd == h {
+			return i, fw, nil
+		}
+	}
+	return -1, nil, fmt.Errorf("no window %d on fake desktop: %w", h, errVanished)
+}
+
+func (d *fakeDesktop) Windows() ([]*window, error) {
+	l := make([]*window, 0, len(d.Wins))
+	for _, fw := range d.Wins {
+		style := fw.Style
+		if fw.Maximize {
+			style |= wsMaximize
+		}
+		p := fw.placement()
+		dpi := fw.DPI
+		if i := monitorIndex(fw.R, d.Displays); dpi == 0 && i >= 0 {
+			dpi = d.Displays[i].DPI
+		}
+		l = append(l, &window{
+			Placement: &p,
+			Hwnd:      fw.Hwnd,
+			Name:      fw.Name,
+			Class:     fw.Class,
+			PID:       fw.PID,
+			Exe:       fw.Exe,
+			Cmdline:   fw.Cmdline,
+			Dir:       fw.Dir,
+			AppID:     fw.AppID,
+			R:         fw.R,
+			DPI:       dpi,
+			visible:   fw.Visible,
+			Style:     style,
+		})
+	}
+	return l, nil
+}
+
+func (d *fakeDesktop) Monitors() ([]*monitor, error) {
+	l := make([]*monitor, 0, len(d.Displays))
+	for _, m := range d.Displays {
+		mm := *m
+		l = append(l, &mm)
+	}
+	return l, nil
+}
+
+func (d *fakeDesktop) Placement(h hwnd) (placement, error) {
+	_, fw, err := d.find(h)
+	if err != nil {
+		return placement{}, err
+	}
+	return fw.placement(), nil
+}
+
+// SetPlacement maximizes windows to the work area of the monitor
+// holding their normal position, and minimizes them to (-32000,-32000).
+func (d *fakeDesktop) SetPlacement(h hwnd, p placement) error {
+	_, fw, err := d.find(h)
+	if err != nil {
+		return err
+	}
+	if fw.Elevated {
+		return fmt.Errorf("window %d is elevated: %w", h, errAccessDenied)
+	}
+	if fw.Fixed {
+		return nil
+	}
+	fw.Normal = p.Normal
+	fw.Maximize = p.State == stateMaximized
+	fw.Minimize = p.State == stateMinimized
+	switch {
+	case fw.Maximize:
+		fw.R = p.Normal
+		if i := monitorIndex(p.Normal, d.Displays); i >= 0 {
+			fw.R = workArea(d.Displays[i])
+		}
+	case fw.Minimize:
+		fw.R = rect{Left: -32000, Top: -32000, Right: -32000 + 160, Bottom: -32000 + 28}
+	case p.snapped():
+		fw.R = p.R
+	default:
+		fw.R = p.Normal
+	}
+	return nil
+}
+
+func (d *fakeDesktop) Restack(order []hwnd) error {
+	top := make([]*fakeWindow, 0, len(d.Wins))
+	stacked := make(map[hwnd]bool)
+	for _, h := range order {
+		_, fw, err := d.find(h)
+		if err != nil {
+			return err
+		}
+		top = append(top, fw)
+		stacked[h] = true
+	}
+	for _, fw := range d.Wins {
+		if !stacked[fw.Hwnd] {
+			top = append(top, fw)
+		}
+	}
+	d.Wins = top
+	return nil
+}
+
+func (d *fakeDesktop) Foreground() hwnd {
+	return d.Active
+}
+
+func (d *fakeDesktop) Focus(h hwnd) error {
+	if err := d.Restack([]hwnd{h}); err != nil {
+		return err
+	}
+	d.Active = h
+	return nil
+}
+
+func (d *fakeDesktop) DisplayChanges(stop <-chan struct{}) (<-chan struct{}, error) {
+	if d.changes == nil {
+		d.changes = make(chan struct{}, 1)
+	}
+	return d.changes, nil
+}
+
+// SetDisplays replaces the monitors of the fake desktop,
+// as when docking or undocking.
+func (d *fakeDesktop) SetDisplays(monitors []*monitor) {
+	d.Displays = monitors
+	if d.changes != nil {
+		select {
+		case d.changes <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Launch opens a new normal window, at the top of the z-order,
+// for the recorded one.
+func (d *fakeDesktop) Launch(w *window) error {
+	if w.Exe == "" && w.AppID == "" {
+		return fmt.Errorf("nothing to launch for '%s'", w.Name)
+	}
+	next := hwnd(1)
+	for _, fw := range d.Wins {
+		if fw.Hwnd >= next {
+			next = fw.Hwnd + 1
+		}
+	}
+	fw := &fakeWindow{
+		Hwnd:    next,
+		Name:    w.Name,
+		Class:   w.Class,
+		PID:     uint32(next),
+		Exe:     w.Exe,
+		Cmdline: w.Cmdline,
+		Dir:     w.Dir,
+		AppID:   w.AppID,
+		R:       rect{Left: 100, Top: 100, Right: 900, Bottom: 700},
+		Visible: true,
+		Style:   wsCaption,
+	}
+	d.Wins = append([]*fakeWindow{fw}, d.Wins...)
+	return nil
+}
+
+// loadFakeDesktop reads a fake desktop description from path.
+// An empty path gives a desktop with one monitor and no window.
+func loadFakeDesktop(path string) (*fakeDesktop, error) {
+	d := &fakeDesktop{}
+	if path == "" {
+		d.Displays = []*monitor{{
+			Device:  `\\.\DISPLAY1`,
+			R:       rect{Right: 1920, Bottom: 1080},
+			Work:    rect{Right: 1920, Bottom: 1040},
+			DPI:     96,
+			Primary: true,
+		}}
+		return d, nil
+	}
+	if err := Load(path, d); err != nil {
+		if os.IsNotExist(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("invalid fake desktop '%s': %v", path, err)
+	}
+	return d, nil
+}
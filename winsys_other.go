@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import "os"
+
+// newWindowSystem gives, off Windows, the fake desktop described
+// by the JSON file named in $WINPOS_FAKE_DESKTOP.
+func newWindowSystem() (WindowSystem, error) {
+	return loadFakeDesktop(os.Getenv("WINPOS_FAKE_DESKTOP"))
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setConfig changes the configuration for the time of a test.
+func setConfig(t *testing.T, change func(c *config)) {
+	t.Helper()
+	saved := conf
+	t.Cleanup(func() { conf = saved })
+	c := defaultConfig()
+	change(c)
+	conf = c
+}
+
+// testMonitors are two side by side monitors, the second one primary.
+func testMonitors() []*monitor {
+	return []*monitor{
+		{Device: `\\.\DISPLAY1`, R: rect{Right: 1920, Bottom: 1080}, Work: rect{Right: 1920, Bottom: 1040}, DPI: 96},
+		{Device: `\\.\DISPLAY2`, R: rect{Left: 1920, Right: 3840, Bottom: 1080}, Work: rect{Left: 1920, Right: 3840, Bottom: 1040}, DPI: 144, Primary: true},
+	}
+}
+
+// testDesktop is a fake desktop on testMonitors with the windows ws,
+// in z-order, which are visible application windows unless said otherwise.
+func testDesktop(ws ...*fakeWindow) *fakeDesktop {
+	for _, fw := range ws {
+		if fw.Style == 0 {
+			fw.Style = wsCaption
+		}
+		if fw.R == (rect{}) {
+			fw.R = rect{100, 100, 900, 700}
+		}
+	}
+	return &fakeDesktop{Displays: testMonitors(), Wins: ws}
+}
+
+func TestListWindows(t *testing.T) {
+	d := testDesktop(
+		&fakeWindow{Hwnd: 1, Name: "tooltip", Style: 0x10000000, Visible: true}, // WS_VISIBLE, without caption
+		&fakeWindow{Hwnd: 2, Name: "a", Visible: true, Maximize: true},
+		&fakeWindow{Hwnd: 3, Name: "hidden"},
+		&fakeWindow{Hwnd: 4, Name: "", Visible: true},
+		&fakeWindow{Hwnd: 5, Name: "c", Visible: true},
+	)
+	d.Active = 5
+	l, err := listWindows(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []hwnd{2, 5}
+	if len(l) != len(want) {
+		t.Fatalf("%d windows listed, want %v", len(l), want)
+	}
+	for i, w := range l {
+		if w.Hwnd != want[i] || w.Z != i {
+			t.Errorf("window %d: %d at z %d, want %d", i, w.Hwnd, w.Z, want[i])
+		}
+		if w.Foreground != (w.Hwnd == 5) {
+			t.Errorf("window %d foreground %t", w.Hwnd, w.Foreground)
+		}
+		if w.Maximize != (w.Hwnd == 2) || !w.Caption {
+			t.Errorf("window %d maximized %t, caption %t", w.Hwnd, w.Maximize, w.Caption)
+		}
+	}
+}
+
+func TestFakeDesktop(t *testing.T) {
+	d := testDesktop(&fakeWindow{Hwnd: 1, Name: "a", Visible: true}, &fakeWindow{Hwnd: 2, Name: "b", Visible: true})
+	normal := rect{2000, 100, 2800, 700}
+	if err := d.SetPlacement(2, placement{State: stateMaximized, Normal: normal, R: normal}); err != nil {
+		t.Fatal(err)
+	}
+	p, err := d.Placement(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (rect{1920, 0, 3840, 1040}); p.State != stateMaximized || p.Normal != normal || p.R != want {
+		t.Errorf("placement %+v, want maximized at %s from %s", p, want, normal)
+	}
+	if err := d.SetPlacement(1, placement{State: stateMinimized, Normal: normal, R: normal}); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := d.Placement(1); p.State != stateMinimized || p.Normal != normal || p.R.Left != -32000 {
+		t.Errorf("placement %+v, want minimized from %s", p, normal)
+	}
+	if err := d.Focus(2); err != nil {
+		t.Fatal(err)
+	}
+	if d.Wins[0].Hwnd != 2 || d.Foreground() != 2 {
+		t.Errorf("window %d on top, %d in the foreground: want 2", d.Wins[0].Hwnd, d.Foreground())
+	}
+	if err := d.Restack([]hwnd{1, 2}); err != nil || d.Wins[0].Hwnd != 1 || d.Wins[1].Hwnd != 2 || d.Active != 2 {
+		t.Errorf("restacked %d, %d, %v: want 1 over 2, 2 still active", d.Wins[0].Hwnd, d.Wins[1].Hwnd, err)
+	}
+	if _, err := d.Placement(3); err == nil {
+		t.Errorf("placement of a missing window")
+	}
+	if err := d.Restack([]hwnd{3}); err == nil {
+		t.Errorf("missing window restacked")
+	}
+}
+
+func TestPlacement(t *testing.T) {
+	normal := rect{100, 100, 900, 700}
+	p := placement{State: stateNormal, Normal: normal, R: normal}
+	snapped := placement{State: stateNormal, Normal: normal, R: rect{-8, 0, 968, 1048}}
+	maximized := placement{State: stateMaximized, Normal: normal, R: rect{-8, -8, 1928, 1048}}
+	maximizedElsewhere := placement{State: stateMaximized, Normal: normal, R: rect{1912, -8, 3848, 1048}}
+	if p.snapped() || !snapped.snapped() || maximized.snapped() {
+		t.Errorf("snapped: %t, %t, %t, want only the snapped placement", p.snapped(), snapped.snapped(), maximized.snapped())
+	}
+	if !maximized.same(maximizedElsewhere) || p.same(snapped) || p.same(maximized) || !snapped.same(snapped) {
+		t.Errorf("same placements told apart, or different ones not")
+	}
+	for want, q := range map[string]placement{
+		"(100,100) 800x600": p,
+		"(-8,0) 976x1048 snapped, normal (100,100) 800x600": snapped,
+		"maximized, normal (100,100) 800x600":               maximized,
+	} {
+		if got := q.String(); got != want {
+			t.Errorf("placement printed %s, want %s", got, want)
+		}
+	}
+}
+
+func TestWindowPlacement(t *testing.T) {
+	// layouts recorded before the placement
+	w := &window{R: rect{0, 0, 1920, 1040}, Maximize: true}
+	if p := w.placement(); p.State != stateMaximized || p.Normal != w.R || p.MinPos != (point{-1, -1}) {
+		t.Errorf("placement %+v of a maximized window without placement", p)
+	}
+	w.Placement = &placement{State: stateMinimized, Normal: rect{1, 2, 3, 4}}
+	if p := w.placement(); p != *w.Placement {
+		t.Errorf("placement %+v, want the recorded one", p)
+	}
+}
+
+func TestLoadFakeDesktop(t *testing.T) {
+	d, err := loadFakeDesktop("")
+	if err != nil || len(d.Displays) != 1 || len(d.Wins) != 0 {
+		t.Errorf("default fake desktop %+v, %v: want one monitor and no window", d, err)
+	}
+	path := filepath.Join(t.TempDir(), "desktop.json")
+	desc := `{"Displays": [{"R": {"Left": 0, "Top": 0, "Right": 1920, "Bottom": 1080}}],
+		"Wins": [{"Hwnd": 1, "Name": "a", "Visible": true, "Style": 281018368, "R": {"Left": 0, "Top": 0, "Right": 800, "Bottom": 600}}]}`
+	if err := os.WriteFile(path, []byte(desc), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if d, err = loadFakeDesktop(path); err != nil {
+		t.Fatal(err)
+	}
+	if l, err := listWindows(d); err != nil || len(l) != 1 || l[0].Name != "a" {
+		t.Errorf("windows %v, %v: want a", l, err)
+	}
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadFakeDesktop(path); err == nil {
+		t.Errorf("invalid fake desktop loaded")
+	}
+}
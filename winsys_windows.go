@@ -0,0 +1,367 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/lxn/win"
+	"golang.org/x/sys/windows"
+)
+
+var (
+	libuser32               *windows.LazyDLL
+	procGetWindowTextW      *windows.LazyProc
+	procEnumDisplayMonitors *windows.LazyProc
+	procEnumWindows         *windows.LazyProc
+	procGetMonitorInfoW     *windows.LazyProc
+	procEnumDisplayDevicesW *windows.LazyProc
+	procSetWindowPlacement  *windows.LazyProc
+	procMoveWindow          *windows.LazyProc
+
+	procSetProcessDpiAwarenessContext *windows.LazyProc
+	procSetProcessDPIAware            *windows.LazyProc
+	procGetDpiForWindow               *windows.LazyProc
+
+	libshcore                  *windows.LazyDLL
+	procGetDpiForMonitor       *windows.LazyProc
+	procSetProcessDpiAwareness *windows.LazyProc
+)
+
+func init() {
+	// Library
+	libuser32 = windows.NewLazySystemDLL("user32.dll")
+	procGetWindowTextW = libuser32.NewProc("GetWindowTextW")
+	procEnumDisplayMonitors = libuser32.NewProc("EnumDisplayMonitors")
+	procEnumWindows = libuser32.NewProc("EnumWindows")
+	procGetMonitorInfoW = libuser32.NewProc("GetMonitorInfoW")
+	procEnumDisplayDevicesW = libuser32.NewProc("EnumDisplayDevicesW")
+	procSetWindowPlacement = libuser32.NewProc("SetWindowPlacement")
+	procMoveWindow = libuser32.NewProc("MoveWindow")
+	procSetProcessDpiAwarenessContext = libuser32.NewProc("SetProcessDpiAwarenessContext")
+	procSetProcessDPIAware = libuser32.NewProc("SetProcessDPIAware")
+	procGetDpiForWindow = libuser32.NewProc("GetDpiForWindow")
+
+	libshcore = windows.NewLazySystemDLL("shcore.dll")
+	procGetDpiForMonitor = libshcore.NewProc("GetDpiForMonitor")
+	procSetProcessDpiAwareness = libshcore.NewProc("SetProcessDpiAwareness")
+
+	setDPIAwareness()
+}
+
+func newWindowSystem() (WindowSystem, error) {
+	return user32{}, nil
+}
+
+// user32 is the actual Windows desktop.
+type user32 struct{}
+
+// The enumeration callbacks are created once: the runtime never frees
+// a callback, and allows only about 2000 of them. They keep what they find
+// in enum, the enumerations being serialized by enumMu.
+var (
+	enumMu      sync.Mutex
+	enum        enumeration
+	enumOnce    sync.Once
+	enumWindows uintptr // perWindow callback
+	enumDisplay uintptr // perMonitor callback
+)
+
+// enumeration is the state of an EnumWindows or EnumDisplayMonitors call.
+type enumeration struct {
+	windows  []*window
+	dx, dy   int32 // workspaceOffset
+	monitors []*monitor
+	err      error
+}
+
+func enumCallbacks() {
+	enumOnce.Do(func() {
+		enumWindows = windows.NewCallback(perWindow)
+		enumDisplay = syscall.NewCallback(perMonitor)
+	})
+}
+
+func (user32) Windows() ([]*window, error) {
+	enumCallbacks()
+	enumMu.Lock()
+	defer enumMu.Unlock()
+	dx, dy := workspaceOffset()
+	enum = enumeration{windows: make([]*window, 0), dx: dx, dy: dy}
+	defer func() { enum = enumeration{} }()
+	_, _, _ = syscall.Syscall(procEnumWindows.Addr(), 2, enumWindows, 0, 0)
+	return enum.windows, nil
+}
+
+func perWindow(h win.HWND, param uintptr) uintptr {
+	// https://go101.org/article/unsafe.html
+	w := window{Hwnd: hwnd(h)}
+	w.visible = win.IsWindowVisible(h)
+	var r win.RECT
+	win.GetWindowRect(h, &r)
+	w.R = rect(r)
+	w.Name = getName(h, procGetWindowTextW)
+	w.Class = getClass(h)
+	w.hasChild = win.GetWindow(h, win.GW_CHILD) != 0
+	w.Style = win.GetWindowLong(h, win.GWL_STYLE)
+	if w.application() {
+		// opening the process of each tooltip and hidden window is slow
+		getProcess(h, &w)
+	}
+	w.DPI = getWindowDPI(h)
+	if p, err := getPlacement(h, enum.dx, enum.dy); err == nil {
+		w.Placement = &p
+	}
+	enum.windows = append(enum.windows, &w)
+	return 1
+}
+
+// getName reads up to Filters.TitleMax characters of the window title.
+func getName(hwnd win.HWND, get *windows.LazyProc) string {
+	bufSiz := conf.Filters.TitleMax // Max length I want to see
+	buf := make([]uint16, bufSiz)
+	siz, _, _ := get.Call(uintptr(hwnd), uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+	if siz == 0 {
+		return ""
+	}
+	name := syscall.UTF16ToString(buf[:siz])
+	if int(siz) == bufSiz-1 {
+		name = name + "\u22EF"
+	}
+	return name
+}
+
+func getClass(hwnd win.HWND) string {
+	var buf [256]uint16
+	siz, err := win.GetClassName(hwnd, &buf[0], len(buf))
+	if err != nil {
+		return ""
+	}
+	return syscall.UTF16ToString(buf[:siz])
+}
+
+// https://github.com/kbinani/screenshot/blob/9ef8b9209e372fbb0c126cc2648e33bece0c9660/screenshot_windows.go
+func (user32) Monitors() ([]*monitor, error) {
+	enumCallbacks()
+	enumMu.Lock()
+	defer enumMu.Unlock()
+	enum = enumeration{monitors: make([]*monitor, 0)}
+	defer func() { enum = enumeration{} }()
+	if !enumDisplayMonitors(win.HDC(0), nil, enumDisplay, 0) {
+		if enum.err != nil {
+			return nil, enum.err
+		}
+		return nil, fmt.Errorf("EnumDisplayMonitors failed")
+	}
+	return enum.monitors, nil
+}
+
+func perMonitor(hMonitor win.HMONITOR, hdcMonitor win.HDC, lprcMonitor *win.RECT, dwData uintptr) uintptr {
+	m, err := getMonitor(hMonitor)
+	if err != nil {
+		enum.err = err
+		return uintptr(0)
+	}
+	enum.monitors = append(enum.monitors, m)
+	return uintptr(1)
+}
+
+// monitorInfoEx is MONITORINFOEXW, which win.MONITORINFO lacks.
+type monitorInfoEx struct {
+	win.MONITORINFO
+	Device [32]uint16 // CCHDEVICENAME
+}
+
+func getMonitor(hMonitor win.HMONITOR) (*monitor, error) {
+	var mi monitorInfoEx
+	mi.CbSize = uint32(unsafe.Sizeof(mi))
+	ret, _, _ := procGetMonitorInfoW.Call(uintptr(hMonitor), uintptr(unsafe.Pointer(&mi)))
+	if ret == 0 {
+		return nil, fmt.Errorf("GetMonitorInfo failed for monitor %d", hMonitor)
+	}
+	device := syscall.UTF16ToString(mi.Device[:])
+	id, serial := getMonitorID(device)
+	return &monitor{
+		Device:   device,
+		DeviceID: id,
+		Serial:   serial,
+		R:        rect(mi.RcMonitor),
+		Work:     rect(mi.RcWork),
+		DPI:      getMonitorDPI(hMonitor),
+		Primary:  mi.DwFlags&win.MONITORINFOF_PRIMARY != 0,
+	}, nil
+}
+
+// getMonitorDPI returns the effective DPI of a monitor,
+// or 96 before Windows 8.1, which has no per-monitor DPI.
+func getMonitorDPI(hMonitor win.HMONITOR) uint32 {
+	const mdtEffectiveDpi = 0
+	if procGetDpiForMonitor.Find() != nil {
+		return 96
+	}
+	var dpiX, dpiY uint32
+	ret, _, _ := procGetDpiForMonitor.Call(uintptr(hMonitor), mdtEffectiveDpi,
+		uintptr(unsafe.Pointer(&dpiX)), uintptr(unsafe.Pointer(&dpiY)))
+	if ret != 0 || dpiX == 0 {
+		return 96
+	}
+	return dpiX
+}
+
+func enumDisplayMonitors(hdc win.HDC, lprcClip *win.RECT, lpfnEnum uintptr, dwData uintptr) bool {
+	ret, _, _ := syscall.Syscall6(procEnumDisplayMonitors.Addr(), 4,
+		uintptr(hdc),
+		uintptr(unsafe.Pointer(lprcClip)),
+		lpfnEnum,
+		dwData,
+		0,
+		0)
+	return int(ret) != 0
+}
+
+// Windows reports the normal position of a WINDOWPLACEMENT in workspace
+// coordinates: relative to the work area of the primary monitor.
+// workspaceOffset returns what to add to them to get screen coordinates.
+func workspaceOffset() (int32, int32) {
+	var mi win.MONITORINFO
+	mi.CbSize = uint32(unsafe.Sizeof(mi))
+	hMonitor := win.MonitorFromWindow(win.GetDesktopWindow(), win.MONITOR_DEFAULTTOPRIMARY)
+	if !win.GetMonitorInfo(hMonitor, &mi) {
+		return 0, 0
+	}
+	return mi.RcWork.Left - mi.RcMonitor.Left, mi.RcWork.Top - mi.RcMonitor.Top
+}
+
+func getPlacement(h win.HWND, dx, dy int32) (placement, error) {
+	var wp win.WINDOWPLACEMENT
+	wp.Length = uint32(unsafe.Sizeof(wp))
+	if !win.GetWindowPlacement(h, &wp) {
+		return placement{}, fmt.Errorf("GetWindowPlacement failed for window %d: %w", h, errVanished)
+	}
+	var r win.RECT
+	if !win.GetWindowRect(h, &r) {
+		return placement{}, fmt.Errorf("GetWindowRect failed for window %d", h)
+	}
+	n := wp.RcNormalPosition
+	p := placement{
+		State:            stateNormal,
+		Normal:           rect{Left: n.Left + dx, Top: n.Top + dy, Right: n.Right + dx, Bottom: n.Bottom + dy},
+		R:                rect(r),
+		MinPos:           point(wp.PtMinPosition),
+		MaxPos:           point(wp.PtMaxPosition),
+		RestoreMaximized: wp.Flags&win.WPF_RESTORETOMAXIMIZED != 0,
+	}
+	switch {
+	case win.IsIconic(h):
+		p.State = stateMinimized
+	case win.IsZoomed(h):
+		p.State = stateMaximized
+	}
+	return p, nil
+}
+
+func (user32) Placement(h hwnd) (placement, error) {
+	dx, dy := workspaceOffset()
+	return getPlacement(win.HWND(h), dx, dy)
+}
+
+func (user32) SetPlacement(h hwnd, p placement) error {
+	dx, dy := workspaceOffset()
+	n := p.Normal
+	wp := win.WINDOWPLACEMENT{
+		ShowCmd:          win.SW_SHOWNOACTIVATE,
+		PtMinPosition:    win.POINT(p.MinPos),
+		PtMaxPosition:    win.POINT(p.MaxPos),
+		RcNormalPosition: win.RECT{Left: n.Left - dx, Top: n.Top - dy, Right: n.Right - dx, Bottom: n.Bottom - dy},
+	}
+	wp.Length = uint32(unsafe.Sizeof(wp))
+	switch p.State {
+	case stateMinimized:
+		wp.ShowCmd = win.SW_SHOWMINNOACTIVE
+		if p.RestoreMaximized {
+			wp.Flags |= win.WPF_RESTORETOMAXIMIZED
+		}
+	case stateMaximized:
+		wp.ShowCmd = win.SW_SHOWMAXIMIZED
+	}
+	// A DPI-aware window moved onto a monitor of another DPI rescales itself,
+	// from its current size: set its placement again, now at the right DPI.
+	dpi := getWindowDPI(win.HWND(h))
+	if err := setWindowPlacement(h, &wp); err != nil {
+		return err
+	}
+	if getWindowDPI(win.HWND(h)) != dpi {
+		if err := setWindowPlacement(h, &wp); err != nil {
+			return err
+		}
+	}
+	// A snapped window has its normal position restored above,
+	// for when it is unsnapped, and its snapped one set here.
+	if p.snapped() {
+		r := p.R
+		ret, _, err := procMoveWindow.Call(uintptr(h), uintptr(r.Left), uintptr(r.Top),
+			uintptr(r.width()), uintptr(r.height()), 1)
+		if ret == 0 {
+			return windowError("MoveWindow", h, err)
+		}
+	}
+	return nil
+}
+
+func setWindowPlacement(h hwnd, wp *win.WINDOWPLACEMENT) error {
+	ret, _, err := procSetWindowPlacement.Call(uintptr(h), uintptr(unsafe.Pointer(wp)))
+	if ret == 0 {
+		return windowError("SetWindowPlacement", h, err)
+	}
+	return nil
+}
+
+// windowError tells why a call on a window failed: access denied to an
+// elevated window, window destroyed, or anything else.
+func windowError(call string, h hwnd, err error) error {
+	switch err {
+	case windows.ERROR_ACCESS_DENIED:
+		return fmt.Errorf("%s failed for window %d: %w", call, h, errAccessDenied)
+	case windows.ERROR_INVALID_WINDOW_HANDLE:
+		return fmt.Errorf("%s failed for window %d: %w", call, h, errVanished)
+	}
+	return fmt.Errorf("%s failed for window %d: %v: %w", call, h, err, errRejected)
+}
+
+// Restack chains the windows in a single BeginDeferWindowPos batch,
+// each one inserted after the previous one.
+func (user32) Restack(order []hwnd) error {
+	if len(order) == 0 {
+		return nil
+	}
+	hdwp := win.BeginDeferWindowPos(int32(len(order)))
+	if hdwp == 0 {
+		return fmt.Errorf("BeginDeferWindowPos failed")
+	}
+	after := win.HWND_TOP
+	for _, h := range order {
+		hdwp = win.DeferWindowPos(hdwp, win.HWND(h), after, 0, 0, 0, 0,
+			win.SWP_NOMOVE|win.SWP_NOSIZE|win.SWP_NOACTIVATE|win.SWP_NOOWNERZORDER)
+		if hdwp == 0 {
+			return fmt.Errorf("DeferWindowPos failed for window %d", h)
+		}
+		after = win.HWND(h)
+	}
+	if !win.EndDeferWindowPos(hdwp) {
+		return fmt.Errorf("EndDeferWindowPos failed")
+	}
+	return nil
+}
+
+func (user32) Foreground() hwnd {
+	return hwnd(win.GetForegroundWindow())
+}
+
+func (user32) Focus(h hwnd) error {
+	if !win.SetForegroundWindow(win.HWND(h)) {
+		return fmt.Errorf("SetForegroundWindow failed for window %d", h)
+	}
+	win.SetFocus(win.HWND(h))
+	return nil
+}